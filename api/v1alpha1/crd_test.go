@@ -0,0 +1,51 @@
+package v1alpha1
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// withoutDescriptions removes the descriptions of a schema, which the chart leaves out like maxDescLen=0.
+func withoutDescriptions(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		delete(v, "description")
+		for key, value := range v {
+			if key == "properties" {
+				// Properties may be named description themselves
+				for _, property := range value.(map[string]any) {
+					withoutDescriptions(property)
+				}
+				continue
+			}
+			withoutDescriptions(value)
+		}
+	case []any:
+		for _, value := range v {
+			withoutDescriptions(value)
+		}
+	}
+}
+
+func readCRD(t *testing.T, path string) any {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var crd any
+	require.NoError(t, yaml.Unmarshal(content, &crd))
+	return crd
+}
+
+// The chart installs its own copy of the CRD, fields missing from it are pruned by the API server.
+func TestChartCRDMatchesGenerated(t *testing.T) {
+	generated := readCRD(t, filepath.Join("..", "..", "crds", "tf-reconcile.lukaspj.io_workspaces.yaml"))
+	chart := readCRD(t, filepath.Join("..", "..", "charts", "terraform-reconciler", "templates", "crd.yaml"))
+
+	withoutDescriptions(generated)
+	assert.Equal(t, generated, chart, "regenerate the chart CRD from crds/ without descriptions")
+}
@@ -14,10 +14,16 @@ type BackendSpec struct {
 	Inputs *apiextensionsv1.JSON `json:"inputs,omitempty"`
 }
 
+// ModuleOutput exposes an output of the module as an output of the workspace.
 type ModuleOutput struct {
 	// Name is the name of the output
-	Name  string `json:"name"`
+	Name string `json:"name"`
+	// Value is the module output to expose, optionally followed by attribute or index traversals such as
+	// subnets[0].id. It is rendered as module.<module>.<value>
 	Value string `json:"value"`
+	// Sensitive marks the output as sensitive, its value is then never stored in the status
+	// +kubebuilder:validation:Optional
+	Sensitive bool `json:"sensitive,omitempty"`
 }
 
 // EnvVar represents an environment variable present in the terraform process.
@@ -58,6 +64,17 @@ type SecretKeySelector struct {
 	Key string `json:"key"`
 }
 
+// RegistryCredential is the API token of a private registry or HCP Terraform host.
+type RegistryCredential struct {
+	// Host is the hostname of the registry, such as app.terraform.io
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9]([a-zA-Z0-9.-]*[a-zA-Z0-9])?$`
+	Host string `json:"host"`
+	// SecretKeyRef selects the key of the Secret holding the token.
+	// +kubebuilder:validation:Required
+	SecretKeyRef SecretKeySelector `json:"secretKeyRef"`
+}
+
 // ProviderSpec defines the desired state of Provider.
 type ProviderSpec struct {
 	// Name is the name of the provider.
@@ -94,11 +111,62 @@ type ModuleSpec struct {
 	Outputs []ModuleOutput `json:"outputs,omitempty"`
 }
 
+// ImportSpec imports existing infrastructure into the workspace state.
+type ImportSpec struct {
+	// To is the address of the resource to import into, e.g. module.my-module.aws_s3_bucket.this
+	// +kubebuilder:validation:Required
+	To string `json:"to"`
+	// ID is the provider specific ID of the existing resource
+	// +kubebuilder:validation:Required
+	ID string `json:"id"`
+}
+
+// MovedSpec records that a resource or module has moved to a new address.
+type MovedSpec struct {
+	// From is the previous address of the resource or module
+	// +kubebuilder:validation:Required
+	From string `json:"from"`
+	// To is the new address of the resource or module
+	// +kubebuilder:validation:Required
+	To string `json:"to"`
+}
+
+// RemovedSpec removes a resource or module from the workspace state.
+type RemovedSpec struct {
+	// Address is the address of the resource or module to remove
+	// +kubebuilder:validation:Required
+	Address string `json:"address"`
+	// Destroy is a flag to indicate if the infrastructure should be destroyed, or only forgotten by terraform
+	// +kubebuilder:default=false
+	Destroy bool `json:"destroy"`
+}
+
+// TimeoutsSpec limits how long terraform operations may run. An operation that runs longer is interrupted,
+// which lets terraform stop gracefully and release the state lock. Unset timeouts do not limit the operation.
+type TimeoutsSpec struct {
+	// Init is the timeout of terraform init
+	// +kubebuilder:validation:Optional
+	Init *metav1.Duration `json:"init,omitempty"`
+	// Plan is the timeout of terraform plan
+	// +kubebuilder:validation:Optional
+	Plan *metav1.Duration `json:"plan,omitempty"`
+	// Apply is the timeout of terraform apply
+	// +kubebuilder:validation:Optional
+	Apply *metav1.Duration `json:"apply,omitempty"`
+	// Destroy is the timeout of terraform destroy
+	// +kubebuilder:validation:Optional
+	Destroy *metav1.Duration `json:"destroy,omitempty"`
+}
+
 // TFSpec defines the config options for executing terraform.
 type TFSpec struct {
 	// Env is a list of environment variables to set for the terraform process
-	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Optional
 	Env []EnvVar `json:"env,omitempty"`
+
+	// Timeouts limit how long terraform operations may run
+	// +kubebuilder:validation:Optional
+	Timeouts *TimeoutsSpec `json:"timeouts,omitempty"`
 }
 
 // AWSAuthConfig defines the AWS authentication configuration
@@ -111,6 +179,135 @@ type AWSAuthConfig struct {
 	// RoleARN is the ARN of the AWS IAM role to assume
 	// +kubebuilder:validation:Required
 	RoleARN string `json:"roleARN"`
+
+	// Audience is the audience of the web identity token, which must match the audience of the
+	// identity provider in IAM
+	// +kubebuilder:default="sts.amazonaws.com"
+	// +kubebuilder:validation:Optional
+	Audience string `json:"audience,omitempty"`
+}
+
+// AzureAuthConfig defines the Azure workload identity authentication configuration
+type AzureAuthConfig struct {
+	// ServiceAccountName is the name of the ServiceAccount to use for Azure authentication
+	// The ServiceAccount must be in the same namespace as the Workspace
+	// +kubebuilder:validation:Required
+	ServiceAccountName string `json:"serviceAccountName"`
+
+	// ClientID is the client ID of the Azure AD application or managed identity with a federated
+	// credential for the ServiceAccount
+	// +kubebuilder:validation:Required
+	ClientID string `json:"clientID"`
+
+	// TenantID is the ID of the Azure AD tenant of the application or managed identity
+	// +kubebuilder:validation:Required
+	TenantID string `json:"tenantID"`
+
+	// Audience is the audience of the service account token, which must match the audience of the
+	// federated credential
+	// +kubebuilder:default="api://AzureADTokenExchange"
+	// +kubebuilder:validation:Optional
+	Audience string `json:"audience,omitempty"`
+}
+
+// GCPAuthConfig defines the GCP workload identity federation authentication configuration
+type GCPAuthConfig struct {
+	// ServiceAccountName is the name of the ServiceAccount to use for GCP authentication
+	// The ServiceAccount must be in the same namespace as the Workspace
+	// +kubebuilder:validation:Required
+	ServiceAccountName string `json:"serviceAccountName"`
+
+	// Audience is the full resource name of the workload identity pool provider, of the form
+	// //iam.googleapis.com/projects/<number>/locations/global/workloadIdentityPools/<pool>/providers/<provider>
+	// The service account token is issued for the default audience of the provider, which is this name
+	// prefixed with https:
+	// +kubebuilder:validation:Required
+	Audience string `json:"audience"`
+
+	// ServiceAccountEmail is the email of the GCP service account to impersonate. Without it the
+	// federated identity is used directly
+	// +kubebuilder:validation:Optional
+	ServiceAccountEmail string `json:"serviceAccountEmail,omitempty"`
+}
+
+// VaultEnvVar sets an environment variable to a field of the secret read from Vault
+type VaultEnvVar struct {
+	// Name is the name of the environment variable
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Field is the field of the secret data, e.g. access_key
+	// +kubebuilder:validation:Required
+	Field string `json:"field"`
+}
+
+// VaultAuthConfig defines how to get dynamic credentials from Vault for the duration of a run
+type VaultAuthConfig struct {
+	// Address is the address of the Vault server, e.g. https://vault.example.com:8200
+	// +kubebuilder:validation:Required
+	Address string `json:"address"`
+
+	// Namespace is the Vault Enterprise namespace to use
+	// +kubebuilder:validation:Optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// ServiceAccountName is the name of the ServiceAccount whose token is used to log in to Vault
+	// The ServiceAccount must be in the same namespace as the Workspace
+	// +kubebuilder:validation:Required
+	ServiceAccountName string `json:"serviceAccountName"`
+
+	// AuthPath is the path the Kubernetes auth method is mounted at
+	// +kubebuilder:default="kubernetes"
+	// +kubebuilder:validation:Optional
+	AuthPath string `json:"authPath,omitempty"`
+
+	// Role is the role of the Kubernetes auth method to log in as
+	// +kubebuilder:validation:Required
+	Role string `json:"role"`
+
+	// Audience is the audience of the service account token, if the role requires one
+	// +kubebuilder:validation:Optional
+	Audience string `json:"audience,omitempty"`
+
+	// SecretPath is the path of the secret to read, usually dynamic credentials such as aws/creds/terraform.
+	// The lease of the secret is renewed during the run and revoked when it ends
+	// +kubebuilder:validation:Required
+	SecretPath string `json:"secretPath"`
+
+	// Env maps fields of the secret to environment variables of the terraform process
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Env []VaultEnvVar `json:"env"`
+}
+
+// ServiceAccountTokenConfig defines a service account token handed to terraform, for providers that
+// authenticate with OIDC tokens
+type ServiceAccountTokenConfig struct {
+	// ServiceAccountName is the name of the ServiceAccount to issue the token for
+	// The ServiceAccount must be in the same namespace as the Workspace
+	// +kubebuilder:validation:Required
+	ServiceAccountName string `json:"serviceAccountName"`
+
+	// Audiences are the audiences of the token
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Audiences []string `json:"audiences"`
+
+	// Expiration is the lifetime of the token, at least 10m. The token file is refreshed before the token
+	// expires, the token in TokenEnv is not, so it should outlive the run
+	// +kubebuilder:default="1h"
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:XValidation:rule="duration(self) >= duration('10m')",message="expiration must be at least 10m"
+	Expiration *metav1.Duration `json:"expiration,omitempty"`
+
+	// FilePathEnv is the name of the environment variable set to the path of the token file
+	// +kubebuilder:validation:Required
+	FilePathEnv string `json:"filePathEnv"`
+
+	// TokenEnv is the name of an environment variable set to the token itself, for providers that can't
+	// read it from a file
+	// +kubebuilder:validation:Optional
+	TokenEnv string `json:"tokenEnv,omitempty"`
 }
 
 // AuthenticationSpec defines the authentication configuration for the workspace
@@ -118,11 +315,33 @@ type AuthenticationSpec struct {
 	// AWS authentication configuration
 	// +kubebuilder:validation:Optional
 	AWS *AWSAuthConfig `json:"aws,omitempty"`
+
+	// Azure authentication configuration
+	// +kubebuilder:validation:Optional
+	Azure *AzureAuthConfig `json:"azure,omitempty"`
+
+	// GCP authentication configuration
+	// +kubebuilder:validation:Optional
+	GCP *GCPAuthConfig `json:"gcp,omitempty"`
+
+	// Vault dynamic credentials
+	// +kubebuilder:validation:Optional
+	Vault *VaultAuthConfig `json:"vault,omitempty"`
+
+	// ServiceAccountToken is a service account token for any provider with federated authentication
+	// +kubebuilder:validation:Optional
+	ServiceAccountToken *ServiceAccountTokenConfig `json:"serviceAccountToken,omitempty"`
 }
 
 // WorkspaceSpec defines the desired state of Workspace.
 type WorkspaceSpec struct {
-	// TerraformVersion is the version of terraform to use
+	// Engine is the engine running the workspace, either HashiCorp terraform or OpenTofu
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=terraform;opentofu
+	// +kubebuilder:default=terraform
+	Engine string `json:"engine,omitempty"`
+
+	// TerraformVersion is the version of terraform to use, or of tofu if the engine is opentofu
 	// +kubebuilder:validation:Required
 	TerraformVersion string `json:"terraformVersion"`
 
@@ -138,6 +357,18 @@ type WorkspaceSpec struct {
 	// +kubebuilder:validation:Required
 	Module *ModuleSpec `json:"module"`
 
+	// Imports are the existing resources to import into the workspace state
+	// +kubebuilder:validation:Optional
+	Imports []ImportSpec `json:"imports,omitempty"`
+
+	// Moved are the resource and module address changes to apply to the workspace state
+	// +kubebuilder:validation:Optional
+	Moved []MovedSpec `json:"moved,omitempty"`
+
+	// Removed are the resources and modules to remove from the workspace state
+	// +kubebuilder:validation:Optional
+	Removed []RemovedSpec `json:"removed,omitempty"`
+
 	// TFExec is the terraform execution configuration
 	// +kubebuilder:validation:Optional
 	TFExec *TFSpec `json:"tf,omitempty"`
@@ -146,30 +377,268 @@ type WorkspaceSpec struct {
 	// +kubebuilder:default=false
 	AutoApply bool `json:"autoApply"`
 
+	// RenderFormat is the terraform syntax the workspace is rendered in, either native HCL (*.tf) or JSON (*.tf.json)
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=hcl;json
+	// +kubebuilder:default=hcl
+	RenderFormat string `json:"renderFormat,omitempty"`
+
 	// TerraformRC contains the content of the .terraformrc file
 	// +kubebuilder:validation:Optional
 	TerraformRC string `json:"terraformRC,omitempty"`
 
+	// TerraformRCFrom selects a key of a Secret holding CLI configuration, read at run time and appended to
+	// TerraformRC, so configuration with credentials stays out of the Workspace
+	// +kubebuilder:validation:Optional
+	TerraformRCFrom *SecretKeySelector `json:"terraformRCFrom,omitempty"`
+
+	// RegistryCredentials are the API tokens of private registries, read from Secrets at run time and
+	// passed to terraform as TF_TOKEN_<host> environment variables
+	// +kubebuilder:validation:Optional
+	RegistryCredentials []RegistryCredential `json:"registryCredentials,omitempty"`
+
 	// Authentication is the authentication configuration for the workspace
 	// +kubebuilder:validation:Optional
 	Authentication *AuthenticationSpec `json:"authentication,omitempty"`
+
+	// ServiceAccountName is the name of a ServiceAccount in the namespace of the Workspace. The Secrets and
+	// ConfigMaps the workspace references are read as this ServiceAccount, so the workspace can only use
+	// what the ServiceAccount may read. Without it they are read as the default ServiceAccount of the
+	// namespace.
+	// +kubebuilder:validation:Optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+}
+
+// OutputStatus is the resolved value of a workspace output.
+type OutputStatus struct {
+	// Name is the name of the output
+	Name string `json:"name"`
+	// Sensitive is true if terraform considers the output sensitive, in which case Value is omitted
+	Sensitive bool `json:"sensitive,omitempty"`
+	// Value is the JSON encoded value of the output
+	// +kubebuilder:validation:Optional
+	Value *apiextensionsv1.JSON `json:"value,omitempty"`
+}
+
+// PlanSummary summarises the changes of a plan.
+type PlanSummary struct {
+	// Add is the number of resources the plan creates
+	Add int `json:"add"`
+	// Change is the number of resources the plan updates in-place
+	Change int `json:"change"`
+	// Destroy is the number of resources the plan destroys
+	Destroy int `json:"destroy"`
+	// Imports are the addresses of the resources the plan imports
+	// +kubebuilder:validation:Optional
+	Imports []string `json:"imports,omitempty"`
+	// Moves are the address changes of the plan, formatted as "<from> -> <to>"
+	// +kubebuilder:validation:Optional
+	Moves []string `json:"moves,omitempty"`
+	// Forgets are the addresses the plan removes from state without destroying them
+	// +kubebuilder:validation:Optional
+	Forgets []string `json:"forgets,omitempty"`
+	// ProviderChanges are the provider version changes of the provider upgrade the plan follows, formatted
+	// as "<address>: <old> -> <new>"
+	// +kubebuilder:validation:Optional
+	ProviderChanges []string `json:"providerChanges,omitempty"`
+}
+
+// ModuleVariable is a variable declared by the module of the workspace.
+type ModuleVariable struct {
+	// Name is the name of the variable
+	Name string `json:"name"`
+	// Type is the type constraint of the variable in terraform syntax
+	Type string `json:"type"`
+	// Required is true if the variable has no default and must be given as an input
+	Required bool `json:"required"`
+	// Default is the JSON encoded default value of the variable
+	// +kubebuilder:validation:Optional
+	Default *apiextensionsv1.JSON `json:"default,omitempty"`
+}
+
+// InputProblem is a problem with one of the module inputs.
+type InputProblem struct {
+	// Reason classifies the problem
+	// +kubebuilder:validation:Enum=UnknownInput;MissingInput;TypeMismatch
+	Reason string `json:"reason"`
+	// Input is the name of the input or variable the problem concerns
+	Input string `json:"input"`
+	// Message is a human readable description of the problem
+	Message string `json:"message"`
+}
+
+// SourcePos is a position in a configuration file.
+type SourcePos struct {
+	// Line is the line number, starting at 1
+	Line int `json:"line"`
+	// Column is the column number, starting at 1
+	Column int `json:"column"`
+}
+
+// SourceRange is a range in a configuration file.
+type SourceRange struct {
+	// Filename is the name of the file, relative to the workspace directory
+	Filename string `json:"filename"`
+	// Start is the position the range starts at
+	Start SourcePos `json:"start"`
+	// End is the position the range ends at
+	End SourcePos `json:"end"`
+}
+
+// Diagnostic is a diagnostic reported by terraform validate.
+type Diagnostic struct {
+	// Severity is the severity of the diagnostic
+	// +kubebuilder:validation:Enum=error;warning;unknown
+	Severity string `json:"severity"`
+	// Summary is a short description of the diagnostic
+	Summary string `json:"summary"`
+	// Detail is a longer description of the diagnostic
+	// +kubebuilder:validation:Optional
+	Detail string `json:"detail,omitempty"`
+	// Range is the location in the rendered configuration the diagnostic refers to
+	// +kubebuilder:validation:Optional
+	Range *SourceRange `json:"range,omitempty"`
+}
+
+// InputsStatus records the inputs a run of the workspace was based on. A run is skipped when the hash of
+// its inputs matches the hash of the previous run and no refresh is due.
+type InputsStatus struct {
+	// Hash is the combined hash of all the inputs
+	Hash string `json:"hash"`
+	// Render is the hash of the rendered configuration
+	Render string `json:"render"`
+	// Env is the hash of the environment variables, their values are never recorded
+	Env string `json:"env"`
+	// TerraformRC is the hash of the CLI configuration
+	// +kubebuilder:validation:Optional
+	TerraformRC string `json:"terraformRC,omitempty"`
+	// Engine is the engine the run used
+	Engine string `json:"engine"`
+	// Version is the exact engine version the run used
+	Version string `json:"version"`
+	// AutoApply is the auto apply setting the run used
+	AutoApply bool `json:"autoApply"`
+}
+
+// StateLock describes a lock held on the state of a workspace, as reported by terraform.
+type StateLock struct {
+	// ID is the lock ID to pass to the force-unlock annotation
+	ID string `json:"id"`
+	// Path is the path of the locked state
+	// +kubebuilder:validation:Optional
+	Path string `json:"path,omitempty"`
+	// Operation is the operation that took the lock
+	// +kubebuilder:validation:Optional
+	Operation string `json:"operation,omitempty"`
+	// Who is the user and host that took the lock
+	// +kubebuilder:validation:Optional
+	Who string `json:"who,omitempty"`
+	// Created is when the lock was taken
+	// +kubebuilder:validation:Optional
+	Created string `json:"created,omitempty"`
+}
+
+// StateRecoveryStatus describes what an interrupted run left behind.
+type StateRecoveryStatus struct {
+	// ErroredState is true if terraform could not persist the state and wrote it to errored.tfstate instead
+	// +kubebuilder:validation:Optional
+	ErroredState bool `json:"erroredState,omitempty"`
+	// Lock is a lock left on the state
+	// +kubebuilder:validation:Optional
+	Lock *StateLock `json:"lock,omitempty"`
+}
+
+// InterruptedRun describes a run that was interrupted because the operator shut down.
+type InterruptedRun struct {
+	// Operation is the terraform operation the run was carrying out, such as apply
+	Operation string `json:"operation"`
+	// Generation is the generation of the workspace the run was for
+	Generation int64 `json:"generation"`
+	// InterruptedAt is when the run was interrupted
+	InterruptedAt metav1.Time `json:"interruptedAt"`
 }
 
 // WorkspaceStatus defines the observed state of Workspace.
 type WorkspaceStatus struct {
 	// LatestPlan is the latest plan of the workspace
 	LatestPlan string `json:"latestPlan"`
+	// PlanSummary summarises the changes of the latest plan
+	// +kubebuilder:validation:Optional
+	PlanSummary *PlanSummary `json:"planSummary,omitempty"`
 	// CurrentRender is the current render of the workspace
 	CurrentRender string `json:"currentRender"`
 	// ValidRender is the result of the validation of the workspace
 	ValidRender bool `json:"validRender"`
+	// ValidationDiagnostics are the diagnostics reported by the latest validation of the workspace
+	// +kubebuilder:validation:Optional
+	ValidationDiagnostics []Diagnostic `json:"validationDiagnostics,omitempty"`
+	// ModuleVariables are the variables declared by the module, as found after init
+	// +kubebuilder:validation:Optional
+	ModuleVariables []ModuleVariable `json:"moduleVariables,omitempty"`
+	// InputProblems are the problems found when checking the module inputs against the module variables
+	// +kubebuilder:validation:Optional
+	InputProblems []InputProblem `json:"inputProblems,omitempty"`
 	// NextRefreshTimestamp is the next time the workspace will be refreshed
 	// +kubebuilder:validation:Optional
 	NextRefreshTimestamp metav1.Time `json:"nextRefreshTimestamp"`
 	// ObservedGeneration is the observed generation of the workspace
 	ObservedGeneration int64 `json:"observedGeneration"`
+	// Outputs are the outputs of the workspace as of the latest apply
+	// +kubebuilder:validation:Optional
+	Outputs []OutputStatus `json:"outputs,omitempty"`
+	// Inputs are the inputs of the latest run that was not skipped
+	// +kubebuilder:validation:Optional
+	Inputs *InputsStatus `json:"inputs,omitempty"`
+	// ResolvedVersion is the exact engine version the terraform version of the spec resolved to
+	// +kubebuilder:validation:Optional
+	ResolvedVersion string `json:"resolvedVersion,omitempty"`
+	// StateRecovery describes what an interrupted run left behind, if anything
+	// +kubebuilder:validation:Optional
+	StateRecovery *StateRecoveryStatus `json:"stateRecovery,omitempty"`
+	// InterruptedRun is the latest run interrupted by a shutdown of the operator, it is cleared once a run completes
+	// +kubebuilder:validation:Optional
+	InterruptedRun *InterruptedRun `json:"interruptedRun,omitempty"`
+	// Conditions are the current conditions of the workspace
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+const (
+	// ConditionVersionResolved is true when the terraform version of the spec resolved to an available version.
+	ConditionVersionResolved = "VersionResolved"
+	// ConditionPlanSkipped is true when the latest reconcile skipped init and plan because nothing relevant changed,
+	// or skipped plan because the render is invalid.
+	ConditionPlanSkipped = "PlanSkipped"
+	// ConditionStateRecoveryRequired is true when an interrupted run left the state locked or errored, which
+	// must be resolved through the recovery annotations before the workspace is run again.
+	ConditionStateRecoveryRequired = "StateRecoveryRequired"
+	// ConditionCancelled is true when the latest run was stopped before it completed, either because it was
+	// cancelled through AnnotationCancel or because an operation exceeded its timeout.
+	ConditionCancelled = "Cancelled"
+)
+
+const (
+	// AnnotationUpgradeProviders requests that the next run upgrades the providers of the workspace to the
+	// newest versions its constraints allow and regenerates the dependency lock file. The annotation is
+	// removed once a plan has reported the provider changes.
+	AnnotationUpgradeProviders = "tf-reconcile.lukaspj.io/upgrade-providers"
+	// AnnotationPushErroredState requests that errored.tfstate left by an interrupted run is pushed to the backend.
+	AnnotationPushErroredState = "tf-reconcile.lukaspj.io/push-errored-state"
+	// AnnotationForceUnlock requests that the lock with the ID given as the annotation value is removed from the state.
+	AnnotationForceUnlock = "tf-reconcile.lukaspj.io/force-unlock"
+	// AnnotationCancel requests that the run in progress is interrupted. A cancelled run is not retried until
+	// the spec changes or the next refresh is due. The annotation is removed once the run has stopped.
+	AnnotationCancel = "tf-reconcile.lukaspj.io/cancel"
+)
+
+const (
+	// LabelLockFileWorkspace marks the ConfigMap holding the dependency lock file of the workspace named by its
+	// value. ConfigMaps without it are never read or overwritten as lock files.
+	LabelLockFileWorkspace = "tf-reconcile.lukaspj.io/lock-file-workspace"
+)
+
 // Workspace is the Schema for the workspaces API.
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
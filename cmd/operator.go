@@ -3,9 +3,11 @@ package cmd
 import (
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -43,24 +45,98 @@ var operatorCmd = &cobra.Command{
 		slog.SetLogLoggerLevel(slog.LevelInfo)
 		ctrl.SetLogger(logr.FromSlogHandler(slog.Default().Handler()))
 
+		shutdownGracePeriod, err := time.ParseDuration(cfg.ShutdownGracePeriod)
+		if err != nil {
+			slog.Error("invalid shutdown grace period", "error", err)
+			os.Exit(1)
+		}
+		// Interrupted terraform processes are given runner.InterruptWaitDelay to release their locks before they
+		// are killed, and the runs as long again to record that they were interrupted
+		gracefulShutdownTimeout := shutdownGracePeriod + 2*runner.InterruptWaitDelay
+
 		mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 			Scheme:                  scheme,
 			HealthProbeBindAddress:  cfg.ProbeAddr,
 			LeaderElectionNamespace: cfg.Namespace,
 			LeaderElection:          cfg.EnableLeaderElection,
 			LeaderElectionID:        "69943c0d.krec-operator.lukasjp",
+			GracefulShutdownTimeout: &gracefulShutdownTimeout,
 		})
 		if err != nil {
 			slog.Error("unable to start manager", "error", err)
 			os.Exit(1)
 		}
 
+		tf := runner.New(cfg.WorkspacePath)
+		var tofuPublicKey []byte
+		if cfg.TofuPublicKeyFile != "" {
+			tofuPublicKey, err = os.ReadFile(cfg.TofuPublicKeyFile)
+			if err != nil {
+				slog.Error("unable to read tofu public key", "error", err)
+				os.Exit(1)
+			}
+		}
+		tf.SetTofuMirror(cfg.TofuMirror, cfg.TofuIndex, string(tofuPublicKey))
+		switch {
+		case cfg.TerraformDir != "":
+			tf.SetTerraformDir(cfg.TerraformDir)
+		case cfg.TerraformMirror != "":
+			var publicKey []byte
+			if cfg.TerraformMirrorPublicKeyFile != "" {
+				publicKey, err = os.ReadFile(cfg.TerraformMirrorPublicKeyFile)
+				if err != nil {
+					slog.Error("unable to read terraform mirror public key", "error", err)
+					os.Exit(1)
+				}
+			}
+			tf.SetTerraformMirror(cfg.TerraformMirror, string(publicKey))
+		}
+		if cfg.ProviderNetworkMirror != "" || cfg.ProviderFilesystemMirror != "" {
+			tf.ProviderMirror = &runner.ProviderMirror{
+				NetworkURL:     cfg.ProviderNetworkMirror,
+				FilesystemPath: cfg.ProviderFilesystemMirror,
+			}
+		}
+		if cfg.PluginCacheMaxSize != "" {
+			maxSize, err := resource.ParseQuantity(cfg.PluginCacheMaxSize)
+			if err != nil {
+				slog.Error("invalid plugin cache max size", "error", err)
+				os.Exit(1)
+			}
+			tf.PluginCache.MaxBytes = maxSize.Value()
+		}
+
+		switch cfg.Storage {
+		case "", "local":
+		case "s3":
+			tf.Storage, err = runner.NewS3Storage(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3Prefix)
+			if err != nil {
+				slog.Error("unable to set up s3 storage", "error", err)
+				os.Exit(1)
+			}
+		default:
+			slog.Error("unsupported storage", "storage", cfg.Storage)
+			os.Exit(1)
+		}
+
+		var refreshInterval time.Duration
+		if cfg.RefreshInterval != "" {
+			refreshInterval, err = time.ParseDuration(cfg.RefreshInterval)
+			if err != nil {
+				slog.Error("invalid refresh interval", "error", err)
+				os.Exit(1)
+			}
+		}
+
 		reconciler := &controller.WorkspaceReconciler{
 			Client:   mgr.GetClient(),
 			Scheme:   mgr.GetScheme(),
 			Recorder: mgr.GetEventRecorderFor("krec"),
 
-			Tf: runner.New(cfg.WorkspacePath),
+			Tf:              tf,
+			RefreshInterval: refreshInterval,
+			Runs:            controller.NewRunTracker(shutdownGracePeriod),
+			RestConfig:      mgr.GetConfig(),
 		}
 
 		if err = reconciler.SetupWithManager(mgr); err != nil {
@@ -68,6 +144,24 @@ var operatorCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		if cfg.JanitorInterval != "" {
+			janitorInterval, err := time.ParseDuration(cfg.JanitorInterval)
+			if err != nil {
+				slog.Error("invalid janitor interval", "error", err)
+				os.Exit(1)
+			}
+			err = mgr.Add(&controller.Janitor{
+				Client:   mgr.GetClient(),
+				Tf:       tf,
+				Interval: janitorInterval,
+				MinAge:   time.Hour,
+			})
+			if err != nil {
+				slog.Error("unable to add janitor", "error", err)
+				os.Exit(1)
+			}
+		}
+
 		if err = mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 			slog.Error("unable to set up health check", "error", err)
 			os.Exit(1)
@@ -0,0 +1,301 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	authv1 "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// defaultAWSAudience is the audience AWS STS expects web identity tokens to have.
+	defaultAWSAudience = "sts.amazonaws.com"
+	// defaultAzureAudience is the audience Azure AD expects federated tokens to have.
+	defaultAzureAudience = "api://AzureADTokenExchange"
+)
+
+// minTokenExpiration is the shortest lifetime the API server issues service account tokens with.
+const minTokenExpiration = 10 * time.Minute
+
+var (
+	// tokenExpiration is the lifetime requested for service account tokens. Tokens are refreshed well before
+	// they expire, so runs may take longer than this.
+	tokenExpiration = time.Hour
+	// tokenRetryInterval is how long to wait before retrying a failed token refresh.
+	tokenRetryInterval = 10 * time.Second
+)
+
+// tokenFile is a file holding a token of a service account, which is replaced with a new token in the
+// background until it is closed, so the token never expires during a run.
+type tokenFile struct {
+	Path string
+
+	request func(ctx context.Context) (*authv1.TokenRequest, error)
+	stop    context.CancelFunc
+	done    chan struct{}
+}
+
+// requestToken requests a token of the service account of the workspace namespace with the given audiences
+// and lifetime.
+func (r *WorkspaceReconciler) requestToken(ctx context.Context, ws tfreconcilev1alpha1.Workspace, serviceAccountName string, audiences []string, expiration time.Duration) (*authv1.TokenRequest, error) {
+	key := types.NamespacedName{Namespace: ws.Namespace, Name: serviceAccountName}
+	var sa v1.ServiceAccount
+	err := r.Client.Get(ctx, key, &sa)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service account %s in namespace %s: %w", key.Name, key.Namespace, err)
+	}
+
+	expirationSeconds := int64(expiration.Seconds())
+	tokenRequest := &authv1.TokenRequest{
+		Spec: authv1.TokenRequestSpec{
+			Audiences:         audiences,
+			ExpirationSeconds: &expirationSeconds,
+		},
+	}
+	err = r.Client.SubResource("token").Create(ctx, &sa, tokenRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token for service account %s: %w", key.Name, err)
+	}
+
+	return tokenRequest, nil
+}
+
+// startTokenFile writes a token of the service account of the workspace namespace with the given audiences
+// and lifetime to a new temporary file, named after pattern like os.CreateTemp, and keeps it fresh until the
+// file is closed.
+func (r *WorkspaceReconciler) startTokenFile(ctx context.Context, ws tfreconcilev1alpha1.Workspace, serviceAccountName string, audiences []string, expiration time.Duration, pattern string) (*tokenFile, error) {
+	request := func(ctx context.Context) (*authv1.TokenRequest, error) {
+		return r.requestToken(ctx, ws, serviceAccountName, audiences, expiration)
+	}
+
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp token file: %w", err)
+	}
+	f.Close()
+
+	file := &tokenFile{Path: f.Name(), request: request, done: make(chan struct{})}
+	expires, err := file.write(ctx)
+	if err != nil {
+		os.Remove(file.Path)
+		return nil, err
+	}
+
+	refreshCtx, stop := context.WithCancel(ctx)
+	file.stop = stop
+	go file.refresh(refreshCtx, expires)
+
+	return file, nil
+}
+
+// write requests a new token and replaces the content of the file with it, returning when it expires.
+func (f *tokenFile) write(ctx context.Context) (time.Time, error) {
+	tokenRequest, err := f.request(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Terraform may read the file at any time, so it is replaced in one go rather than rewritten
+	tmp := f.Path + ".new"
+	err = os.WriteFile(tmp, []byte(tokenRequest.Status.Token), 0600)
+	if err == nil {
+		err = os.Rename(tmp, f.Path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return time.Time{}, fmt.Errorf("failed to write token to temp file: %w", err)
+	}
+
+	return tokenRequest.Status.ExpirationTimestamp.Time, nil
+}
+
+func (f *tokenFile) refresh(ctx context.Context, expires time.Time) {
+	defer close(f.done)
+	log := logf.FromContext(ctx)
+
+	wait := refreshAfter(expires)
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		next, err := f.write(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error(err, "failed to refresh token", "file", f.Path)
+			}
+			wait = tokenRetryInterval
+			continue
+		}
+		wait = refreshAfter(next)
+	}
+}
+
+// refreshAfter is how long to wait before refreshing a token that expires at the given time, which leaves a
+// fifth of its remaining lifetime to spare.
+func refreshAfter(expires time.Time) time.Duration {
+	return max(time.Until(expires)*4/5, 0)
+}
+
+// Close stops refreshing the token and removes the file.
+func (f *tokenFile) Close() error {
+	f.stop()
+	<-f.done
+
+	err := os.Remove(f.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove token file: %w", err)
+	}
+	return nil
+}
+
+// setupAWSAuthentication provides a web identity token of the configured service account, refreshed for the
+// duration of the run, and the role to assume with it.
+func (r *WorkspaceReconciler) setupAWSAuthentication(ctx context.Context, ws tfreconcilev1alpha1.Workspace) (map[string]string, *tokenFile, error) {
+	aws := ws.Spec.Authentication.AWS
+	audience := aws.Audience
+	if audience == "" {
+		audience = defaultAWSAudience
+	}
+
+	file, err := r.startTokenFile(ctx, ws, aws.ServiceAccountName, []string{audience}, tokenExpiration, fmt.Sprintf("aws-token-%s-%s-*", ws.Namespace, ws.Name))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return map[string]string{
+		"AWS_WEB_IDENTITY_TOKEN_FILE": file.Path,
+		"AWS_ROLE_ARN":                aws.RoleARN,
+	}, file, nil
+}
+
+// setupAzureAuthentication provides a federated token of the configured service account, refreshed for the
+// duration of the run, and the application to exchange it for.
+func (r *WorkspaceReconciler) setupAzureAuthentication(ctx context.Context, ws tfreconcilev1alpha1.Workspace) (map[string]string, *tokenFile, error) {
+	azure := ws.Spec.Authentication.Azure
+	audience := azure.Audience
+	if audience == "" {
+		audience = defaultAzureAudience
+	}
+
+	file, err := r.startTokenFile(ctx, ws, azure.ServiceAccountName, []string{audience}, tokenExpiration, fmt.Sprintf("azure-token-%s-%s-*", ws.Namespace, ws.Name))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return map[string]string{
+		"ARM_USE_OIDC":             "true",
+		"ARM_OIDC_TOKEN_FILE_PATH": file.Path,
+		"ARM_CLIENT_ID":            azure.ClientID,
+		"ARM_TENANT_ID":            azure.TenantID,
+	}, file, nil
+}
+
+// gcpCredentials is the token file of GCP authentication and the credentials file pointing at it.
+type gcpCredentials struct {
+	token *tokenFile
+	path  string
+}
+
+// Close stops refreshing the token and removes both files.
+func (c *gcpCredentials) Close() error {
+	errs := []error{c.token.Close()}
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		errs = append(errs, fmt.Errorf("failed to remove credentials file: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// setupGCPAuthentication provides an external account credentials file that exchanges a token of the
+// configured service account, refreshed for the duration of the run, through workload identity federation.
+func (r *WorkspaceReconciler) setupGCPAuthentication(ctx context.Context, ws tfreconcilev1alpha1.Workspace) (map[string]string, *gcpCredentials, error) {
+	gcp := ws.Spec.Authentication.GCP
+	tokenAudience := gcp.Audience
+	if strings.HasPrefix(tokenAudience, "//") {
+		tokenAudience = "https:" + tokenAudience
+	}
+
+	token, err := r.startTokenFile(ctx, ws, gcp.ServiceAccountName, []string{tokenAudience}, tokenExpiration, fmt.Sprintf("gcp-token-%s-%s-*", ws.Namespace, ws.Name))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config := map[string]any{
+		"type":               "external_account",
+		"audience":           gcp.Audience,
+		"subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+		"token_url":          "https://sts.googleapis.com/v1/token",
+		"credential_source": map[string]any{
+			"file":   token.Path,
+			"format": map[string]string{"type": "text"},
+		},
+	}
+	if gcp.ServiceAccountEmail != "" {
+		config["service_account_impersonation_url"] = fmt.Sprintf("https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/%s:generateAccessToken", gcp.ServiceAccountEmail)
+	}
+	content, err := json.Marshal(config)
+	if err != nil {
+		token.Close()
+		return nil, nil, fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+
+	f, err := os.CreateTemp("", fmt.Sprintf("gcp-credentials-%s-%s-*", ws.Namespace, ws.Name))
+	if err != nil {
+		token.Close()
+		return nil, nil, fmt.Errorf("failed to create credentials file: %w", err)
+	}
+	defer f.Close()
+	credentials := &gcpCredentials{token: token, path: f.Name()}
+	if _, err := f.Write(content); err != nil {
+		credentials.Close()
+		return nil, nil, fmt.Errorf("failed to write credentials file: %w", err)
+	}
+
+	return map[string]string{
+		"GOOGLE_APPLICATION_CREDENTIALS": credentials.path,
+	}, credentials, nil
+}
+
+// setupServiceAccountToken provides a token of the configured service account in a file, refreshed for the
+// duration of the run, and optionally the token itself.
+func (r *WorkspaceReconciler) setupServiceAccountToken(ctx context.Context, ws tfreconcilev1alpha1.Workspace) (map[string]string, *tokenFile, error) {
+	config := ws.Spec.Authentication.ServiceAccountToken
+	expiration := tokenExpiration
+	if config.Expiration != nil {
+		expiration = config.Expiration.Duration
+	}
+	// The CRD validates it too, but workspaces created before it did are only caught here
+	if expiration < minTokenExpiration {
+		return nil, nil, fmt.Errorf("expiration %s of the service account token is shorter than %s", expiration, minTokenExpiration)
+	}
+
+	file, err := r.startTokenFile(ctx, ws, config.ServiceAccountName, config.Audiences, expiration, fmt.Sprintf("sa-token-%s-%s-*", ws.Namespace, ws.Name))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	envs := map[string]string{config.FilePathEnv: file.Path}
+	if config.TokenEnv != "" {
+		token, err := os.ReadFile(file.Path)
+		if err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("failed to read token file: %w", err)
+		}
+		envs[config.TokenEnv] = string(token)
+	}
+
+	return envs, file, nil
+}
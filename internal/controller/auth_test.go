@@ -0,0 +1,279 @@
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func newAWSWorkspace() tfreconcilev1alpha1.Workspace {
+	return tfreconcilev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws", Namespace: "default"},
+		Spec: tfreconcilev1alpha1.WorkspaceSpec{
+			Authentication: &tfreconcilev1alpha1.AuthenticationSpec{
+				AWS: &tfreconcilev1alpha1.AWSAuthConfig{
+					ServiceAccountName: "terraform",
+					RoleARN:            "arn:aws:iam::123456789012:role/terraform",
+				},
+			},
+		},
+	}
+}
+
+func newServiceAccount() *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "terraform", Namespace: "default"}}
+}
+
+// newTokenClient returns a client whose token requests are handled by issue.
+func newTokenClient(t *testing.T, issue func(tr *authv1.TokenRequest)) client.Client {
+	return fakeclient.NewClientBuilder().
+		WithScheme(newLifecycleScheme(t)).
+		WithObjects(newServiceAccount()).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+				tr, ok := subResource.(*authv1.TokenRequest)
+				if subResourceName != "token" || !ok {
+					return fmt.Errorf("unexpected subresource %s", subResourceName)
+				}
+				issue(tr)
+				return nil
+			},
+		}).
+		Build()
+}
+
+func TestGetEnvsForExecutionAWS(t *testing.T) {
+	var audiences []string
+	r := &WorkspaceReconciler{Client: newTokenClient(t, func(tr *authv1.TokenRequest) {
+		audiences = tr.Spec.Audiences
+		tr.Status.Token = "token"
+		tr.Status.ExpirationTimestamp = metav1.NewTime(time.Now().Add(time.Hour))
+	})}
+
+	envs, cleanup, err := r.getEnvsForExecution(context.Background(), newAWSWorkspace())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sts.amazonaws.com"}, audiences)
+	assert.Equal(t, "arn:aws:iam::123456789012:role/terraform", envs["AWS_ROLE_ARN"])
+	path := envs["AWS_WEB_IDENTITY_TOKEN_FILE"]
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "token", string(content))
+
+	cleanup()
+	assert.NoFileExists(t, path)
+}
+
+func TestGetEnvsForExecutionAWSMissingServiceAccount(t *testing.T) {
+	ws := newAWSWorkspace()
+	ws.Spec.Authentication.AWS.ServiceAccountName = "missing"
+	r := &WorkspaceReconciler{Client: newTokenClient(t, func(tr *authv1.TokenRequest) {})}
+
+	_, _, err := r.getEnvsForExecution(context.Background(), ws)
+	assert.ErrorContains(t, err, "failed to get service account missing")
+}
+
+func TestGetEnvsForExecutionAzure(t *testing.T) {
+	ws := newAWSWorkspace()
+	ws.Spec.Authentication = &tfreconcilev1alpha1.AuthenticationSpec{
+		Azure: &tfreconcilev1alpha1.AzureAuthConfig{
+			ServiceAccountName: "terraform",
+			ClientID:           "client-id",
+			TenantID:           "tenant-id",
+		},
+	}
+	var audiences []string
+	r := &WorkspaceReconciler{Client: newTokenClient(t, func(tr *authv1.TokenRequest) {
+		audiences = tr.Spec.Audiences
+		tr.Status.Token = "token"
+		tr.Status.ExpirationTimestamp = metav1.NewTime(time.Now().Add(time.Hour))
+	})}
+
+	envs, cleanup, err := r.getEnvsForExecution(context.Background(), ws)
+	require.NoError(t, err)
+	defer cleanup()
+	assert.Equal(t, []string{"api://AzureADTokenExchange"}, audiences)
+	assert.Equal(t, "true", envs["ARM_USE_OIDC"])
+	assert.Equal(t, "client-id", envs["ARM_CLIENT_ID"])
+	assert.Equal(t, "tenant-id", envs["ARM_TENANT_ID"])
+	content, err := os.ReadFile(envs["ARM_OIDC_TOKEN_FILE_PATH"])
+	require.NoError(t, err)
+	assert.Equal(t, "token", string(content))
+}
+
+func TestGetEnvsForExecutionGCP(t *testing.T) {
+	const audience = "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/k8s"
+	ws := newAWSWorkspace()
+	ws.Spec.Authentication = &tfreconcilev1alpha1.AuthenticationSpec{
+		GCP: &tfreconcilev1alpha1.GCPAuthConfig{
+			ServiceAccountName:  "terraform",
+			Audience:            audience,
+			ServiceAccountEmail: "terraform@project.iam.gserviceaccount.com",
+		},
+	}
+	var audiences []string
+	r := &WorkspaceReconciler{Client: newTokenClient(t, func(tr *authv1.TokenRequest) {
+		audiences = tr.Spec.Audiences
+		tr.Status.Token = "token"
+		tr.Status.ExpirationTimestamp = metav1.NewTime(time.Now().Add(time.Hour))
+	})}
+
+	envs, cleanup, err := r.getEnvsForExecution(context.Background(), ws)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https:" + audience}, audiences)
+
+	path := envs["GOOGLE_APPLICATION_CREDENTIALS"]
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var credentials struct {
+		Type                           string `json:"type"`
+		Audience                       string `json:"audience"`
+		ServiceAccountImpersonationURL string `json:"service_account_impersonation_url"`
+		CredentialSource               struct {
+			File string `json:"file"`
+		} `json:"credential_source"`
+	}
+	require.NoError(t, json.Unmarshal(content, &credentials))
+	assert.Equal(t, "external_account", credentials.Type)
+	assert.Equal(t, audience, credentials.Audience)
+	assert.Equal(t, "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/terraform@project.iam.gserviceaccount.com:generateAccessToken", credentials.ServiceAccountImpersonationURL)
+	token, err := os.ReadFile(credentials.CredentialSource.File)
+	require.NoError(t, err)
+	assert.Equal(t, "token", string(token))
+
+	cleanup()
+	assert.NoFileExists(t, path)
+	assert.NoFileExists(t, credentials.CredentialSource.File)
+}
+
+func TestGetEnvsForExecutionServiceAccountToken(t *testing.T) {
+	ws := newAWSWorkspace()
+	ws.Spec.Authentication = &tfreconcilev1alpha1.AuthenticationSpec{
+		ServiceAccountToken: &tfreconcilev1alpha1.ServiceAccountTokenConfig{
+			ServiceAccountName: "terraform",
+			Audiences:          []string{"vault", "github"},
+			Expiration:         &metav1.Duration{Duration: 2 * time.Hour},
+			FilePathEnv:        "TF_VAR_token_file",
+			TokenEnv:           "TF_VAR_token",
+		},
+	}
+	var request authv1.TokenRequestSpec
+	r := &WorkspaceReconciler{Client: newTokenClient(t, func(tr *authv1.TokenRequest) {
+		request = tr.Spec
+		tr.Status.Token = "token"
+		tr.Status.ExpirationTimestamp = metav1.NewTime(time.Now().Add(2 * time.Hour))
+	})}
+
+	envs, cleanup, err := r.getEnvsForExecution(context.Background(), ws)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vault", "github"}, request.Audiences)
+	assert.Equal(t, int64(7200), *request.ExpirationSeconds)
+	assert.Equal(t, "token", envs["TF_VAR_token"])
+	path := envs["TF_VAR_token_file"]
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "token", string(content))
+	assert.ElementsMatch(t, []string{"TF_VAR_token_file", "TF_VAR_token"}, volatileAuthEnvs(ws))
+
+	cleanup()
+	assert.NoFileExists(t, path)
+}
+
+func newServiceAccountTokenWorkspace(expiration time.Duration) tfreconcilev1alpha1.Workspace {
+	ws := newAWSWorkspace()
+	ws.Spec.Authentication = &tfreconcilev1alpha1.AuthenticationSpec{
+		ServiceAccountToken: &tfreconcilev1alpha1.ServiceAccountTokenConfig{
+			ServiceAccountName: "terraform",
+			Audiences:          []string{"vault"},
+			Expiration:         &metav1.Duration{Duration: expiration},
+			FilePathEnv:        "TF_VAR_token_file",
+		},
+	}
+	return ws
+}
+
+func TestGetEnvsForExecutionServiceAccountTokenTooShort(t *testing.T) {
+	var requested bool
+	r := &WorkspaceReconciler{Client: newTokenClient(t, func(tr *authv1.TokenRequest) { requested = true })}
+
+	_, _, err := r.getEnvsForExecution(context.Background(), newServiceAccountTokenWorkspace(5*time.Minute))
+	assert.ErrorContains(t, err, "shorter than 10m0s")
+	assert.False(t, requested, "the API server would reject the request")
+}
+
+func TestServiceAccountTokenExpirationValidationEnvtest(t *testing.T) {
+	c := startTestEnv(t, newLifecycleScheme(t))
+	ctx := context.Background()
+
+	ws := newWorkspace()
+	ws.Spec.Authentication = newServiceAccountTokenWorkspace(5 * time.Minute).Spec.Authentication
+	err := c.Create(ctx, ws)
+	require.Error(t, err)
+	assert.True(t, apierrors.IsInvalid(err), "%v", err)
+	assert.ErrorContains(t, err, "expiration must be at least 10m")
+
+	ws.Spec.Authentication.ServiceAccountToken.Expiration.Duration = minTokenExpiration
+	require.NoError(t, c.Create(ctx, ws))
+}
+
+func TestTokenFileRefresh(t *testing.T) {
+	var issued atomic.Int32
+	r := &WorkspaceReconciler{Client: newTokenClient(t, func(tr *authv1.TokenRequest) {
+		tr.Status.Token = fmt.Sprintf("token-%d", issued.Add(1))
+		tr.Status.ExpirationTimestamp = metav1.NewTime(time.Now().Add(50 * time.Millisecond))
+	})}
+
+	file, err := r.startTokenFile(context.Background(), newAWSWorkspace(), "terraform", nil, time.Hour, "token-*")
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		content, err := os.ReadFile(file.Path)
+		return err == nil && string(content) != "token-1"
+	}, time.Second, 10*time.Millisecond, "token is refreshed before it expires")
+
+	require.NoError(t, file.Close())
+	assert.NoFileExists(t, file.Path)
+	refreshed := issued.Load()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, refreshed, issued.Load(), "refreshes stop once closed")
+}
+
+func TestAWSAuthenticationEnvtest(t *testing.T) {
+	c := startTestEnv(t, newLifecycleScheme(t))
+	ctx := context.Background()
+	require.NoError(t, c.Create(ctx, newServiceAccount()))
+	r := &WorkspaceReconciler{Client: c}
+
+	envs, cleanup, err := r.getEnvsForExecution(ctx, newAWSWorkspace())
+	require.NoError(t, err)
+	defer cleanup()
+
+	token, err := os.ReadFile(envs["AWS_WEB_IDENTITY_TOKEN_FILE"])
+	require.NoError(t, err)
+	parts := strings.Split(string(token), ".")
+	require.Len(t, parts, 3, "token is a JWT")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	var claims struct {
+		Aud []string `json:"aud"`
+		Sub string   `json:"sub"`
+	}
+	require.NoError(t, json.Unmarshal(payload, &claims))
+	assert.Equal(t, []string{"sts.amazonaws.com"}, claims.Aud)
+	assert.Equal(t, "system:serviceaccount:default:terraform", claims.Sub)
+}
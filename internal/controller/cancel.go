@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+// cancelPollInterval is how often a run checks whether it has been asked to cancel.
+var cancelPollInterval = 5 * time.Second
+
+var errCancelRequested = errors.New("run cancelled on request")
+
+func cancelRequested(ws tfreconcilev1alpha1.Workspace) bool {
+	_, ok := ws.Annotations[tfreconcilev1alpha1.AnnotationCancel]
+	return ok
+}
+
+// clearCancelRequest removes the cancel annotation.
+func (r *WorkspaceReconciler) clearCancelRequest(ctx context.Context, ws *tfreconcilev1alpha1.Workspace) error {
+	return r.removeAnnotations(ctx, ws, tfreconcilev1alpha1.AnnotationCancel)
+}
+
+// watchCancel returns a context for the terraform operations of a run, which is cancelled with
+// errCancelRequested once the workspace is annotated with the cancel annotation. The returned function stops
+// watching. A run holds on to its workspace until it ends, so the workspace is polled through the cache.
+func (r *WorkspaceReconciler) watchCancel(ctx context.Context, key types.NamespacedName) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		ticker := time.NewTicker(cancelPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			var ws tfreconcilev1alpha1.Workspace
+			if err := r.Client.Get(ctx, key, &ws); err == nil && cancelRequested(ws) {
+				cancel(errCancelRequested)
+				return
+			}
+		}
+	}()
+
+	return ctx, func() { cancel(nil) }
+}
+
+// operationContext bounds a terraform operation by its timeout, if one is set. When the deadline passes
+// terraform-exec interrupts terraform, which gives it the chance to stop gracefully and release the state lock.
+func operationContext(ctx context.Context, timeout *metav1.Duration) (context.Context, context.CancelFunc) {
+	if timeout == nil || timeout.Duration <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout.Duration)
+}
+
+// timeouts returns the operation timeouts of the workspace.
+func timeouts(ws tfreconcilev1alpha1.Workspace) tfreconcilev1alpha1.TimeoutsSpec {
+	if ws.Spec.TFExec == nil || ws.Spec.TFExec.Timeouts == nil {
+		return tfreconcilev1alpha1.TimeoutsSpec{}
+	}
+	return *ws.Spec.TFExec.Timeouts
+}
+
+// operationFailed handles a terraform operation run in dir that failed because it left the state to be
+// recovered, was cancelled or timed out. It returns false if the failure is none of those.
+func (r *WorkspaceReconciler) operationFailed(ctx context.Context, ws *tfreconcilev1alpha1.Workspace, dir string, opCtx context.Context, operation string, opErr error) (bool, error) {
+	if recovering, err := r.recoverAfterFailure(ctx, ws, dir, opErr); recovering || err != nil {
+		return true, err
+	}
+
+	if errors.Is(context.Cause(opCtx), errCancelRequested) {
+		return true, r.markCancelled(ctx, ws, operation)
+	}
+
+	if errors.Is(opCtx.Err(), context.DeadlineExceeded) {
+		deadline, _ := opCtx.Deadline()
+		err := fmt.Errorf("terraform %s timed out at %s: %w", operation, deadline.Format(time.RFC3339), opErr)
+		r.Recorder.Eventf(ws, v1.EventTypeWarning, TFErrEventReason, err.Error())
+		meta.SetStatusCondition(&ws.Status.Conditions, metav1.Condition{
+			Type:               tfreconcilev1alpha1.ConditionCancelled,
+			Status:             metav1.ConditionTrue,
+			Reason:             "Timeout",
+			Message:            fmt.Sprintf("terraform %s exceeded its timeout", operation),
+			ObservedGeneration: ws.Generation,
+		})
+		if updateErr := r.Client.Status().Update(ctx, ws); updateErr != nil {
+			return true, fmt.Errorf("failed to update workspace status: %w", updateErr)
+		}
+		return true, err
+	}
+
+	return false, nil
+}
+
+// markCancelled records that the run was cancelled on request and removes the cancel annotation. The
+// generation counts as observed so the run is not started again right away.
+func (r *WorkspaceReconciler) markCancelled(ctx context.Context, ws *tfreconcilev1alpha1.Workspace, operation string) error {
+	r.Recorder.Eventf(ws, v1.EventTypeNormal, TFCancelEventReason, "Terraform %s cancelled on request", operation)
+	meta.SetStatusCondition(&ws.Status.Conditions, metav1.Condition{
+		Type:               tfreconcilev1alpha1.ConditionCancelled,
+		Status:             metav1.ConditionTrue,
+		Reason:             "CancelRequested",
+		Message:            fmt.Sprintf("terraform %s was cancelled on request", operation),
+		ObservedGeneration: ws.Generation,
+	})
+	r.scheduleRefresh(ws)
+	ws.Status.ObservedGeneration = ws.Generation
+	err := r.Client.Status().Update(ctx, ws)
+	if err != nil {
+		return fmt.Errorf("failed to update workspace status: %w", err)
+	}
+
+	return r.clearCancelRequest(ctx, ws)
+}
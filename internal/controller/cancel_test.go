@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"lukaspj.io/kube-tf-reconciler/pkg/runner"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newCancelTestReconciler(t *testing.T, ws *tfreconcilev1alpha1.Workspace) (*WorkspaceReconciler, *record.FakeRecorder) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, tfreconcilev1alpha1.AddToScheme(scheme))
+
+	recorder := record.NewFakeRecorder(10)
+	return &WorkspaceReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(ws).WithStatusSubresource(ws).Build(),
+		Recorder: recorder,
+		Tf:       runner.New(t.TempDir()),
+	}, recorder
+}
+
+func TestOperationContext(t *testing.T) {
+	ctx, cancel := operationContext(context.Background(), nil)
+	defer cancel()
+	_, ok := ctx.Deadline()
+	assert.False(t, ok, "no timeout means no deadline")
+
+	ctx, cancel = operationContext(context.Background(), &metav1.Duration{Duration: time.Minute})
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+}
+
+func TestWatchCancel(t *testing.T) {
+	defer func(interval time.Duration) { cancelPollInterval = interval }(cancelPollInterval)
+	cancelPollInterval = 10 * time.Millisecond
+
+	ws := &tfreconcilev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws", Namespace: "default"},
+	}
+	r, _ := newCancelTestReconciler(t, ws)
+
+	ctx, stop := r.watchCancel(context.Background(), types.NamespacedName{Namespace: "default", Name: "ws"})
+	defer stop()
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("run cancelled without a cancel request")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ws.Annotations = map[string]string{tfreconcilev1alpha1.AnnotationCancel: ""}
+	require.NoError(t, r.Client.Update(context.Background(), ws))
+
+	select {
+	case <-ctx.Done():
+		assert.ErrorIs(t, context.Cause(ctx), errCancelRequested)
+	case <-time.After(time.Second):
+		t.Fatal("run not cancelled after the cancel request")
+	}
+}
+
+func TestOperationFailedCancelled(t *testing.T) {
+	ws := &tfreconcilev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "ws",
+			Namespace:   "default",
+			Generation:  2,
+			Annotations: map[string]string{tfreconcilev1alpha1.AnnotationCancel: ""},
+		},
+	}
+	r, recorder := newCancelTestReconciler(t, ws)
+	ctx := context.Background()
+
+	opCtx, cancel := context.WithCancelCause(ctx)
+	cancel(errCancelRequested)
+
+	handled, err := r.operationFailed(ctx, ws, t.TempDir(), opCtx, "apply", errors.New("exit status 1"))
+	require.NoError(t, err)
+	assert.True(t, handled)
+	assert.Len(t, recorder.Events, 1)
+
+	var got tfreconcilev1alpha1.Workspace
+	require.NoError(t, r.Client.Get(ctx, types.NamespacedName{Namespace: "default", Name: "ws"}, &got))
+	assert.NotContains(t, got.Annotations, tfreconcilev1alpha1.AnnotationCancel)
+	assert.Equal(t, int64(2), got.Status.ObservedGeneration)
+	cond := meta.FindStatusCondition(got.Status.Conditions, tfreconcilev1alpha1.ConditionCancelled)
+	require.NotNil(t, cond)
+	assert.Equal(t, metav1.ConditionTrue, cond.Status)
+	assert.Equal(t, "CancelRequested", cond.Reason)
+}
+
+func TestOperationFailedTimeout(t *testing.T) {
+	ws := &tfreconcilev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws", Namespace: "default", Generation: 2},
+	}
+	r, _ := newCancelTestReconciler(t, ws)
+	ctx := context.Background()
+
+	opCtx, cancel := operationContext(ctx, &metav1.Duration{Duration: time.Nanosecond})
+	defer cancel()
+	<-opCtx.Done()
+
+	handled, err := r.operationFailed(ctx, ws, t.TempDir(), opCtx, "plan", errors.New("signal: interrupt"))
+	assert.True(t, handled)
+	assert.ErrorContains(t, err, "terraform plan timed out")
+
+	cond := meta.FindStatusCondition(ws.Status.Conditions, tfreconcilev1alpha1.ConditionCancelled)
+	require.NotNil(t, cond)
+	assert.Equal(t, "Timeout", cond.Reason)
+	assert.Zero(t, ws.Status.ObservedGeneration, "a timed out run is retried")
+}
+
+func TestOperationFailedOtherError(t *testing.T) {
+	ws := &tfreconcilev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws", Namespace: "default"},
+	}
+	r, _ := newCancelTestReconciler(t, ws)
+	ctx := context.Background()
+
+	handled, err := r.operationFailed(ctx, ws, t.TempDir(), ctx, "plan", errors.New("exit status 1"))
+	require.NoError(t, err)
+	assert.False(t, handled)
+}
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"fmt"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+// convertDiagnostics converts the diagnostics of terraform validate into their status representation.
+func convertDiagnostics(diags []tfjson.Diagnostic) []tfreconcilev1alpha1.Diagnostic {
+	var converted []tfreconcilev1alpha1.Diagnostic
+	for _, d := range diags {
+		diag := tfreconcilev1alpha1.Diagnostic{
+			Severity: string(d.Severity),
+			Summary:  d.Summary,
+			Detail:   d.Detail,
+		}
+		if diag.Severity == "" {
+			diag.Severity = string(tfjson.DiagnosticSeverityUnknown)
+		}
+		if d.Range != nil {
+			diag.Range = &tfreconcilev1alpha1.SourceRange{
+				Filename: d.Range.Filename,
+				Start:    tfreconcilev1alpha1.SourcePos{Line: d.Range.Start.Line, Column: d.Range.Start.Column},
+				End:      tfreconcilev1alpha1.SourcePos{Line: d.Range.End.Line, Column: d.Range.End.Column},
+			}
+		}
+		converted = append(converted, diag)
+	}
+
+	return converted
+}
+
+// diagnosticMessage formats a diagnostic on a single line, prefixed with its location if it has one.
+func diagnosticMessage(d tfreconcilev1alpha1.Diagnostic) string {
+	msg := fmt.Sprintf("%s: %s", d.Severity, d.Summary)
+	if d.Range != nil {
+		msg = fmt.Sprintf("%s:%d,%d: %s", d.Range.Filename, d.Range.Start.Line, d.Range.Start.Column, msg)
+	}
+	if d.Detail != "" {
+		msg = fmt.Sprintf("%s; %s", msg, d.Detail)
+	}
+
+	return msg
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+func TestConvertDiagnostics(t *testing.T) {
+	diags := convertDiagnostics([]tfjson.Diagnostic{
+		{
+			Severity: tfjson.DiagnosticSeverityError,
+			Summary:  "Unsupported argument",
+			Detail:   `An argument named "nmae" is not expected here.`,
+			Range: &tfjson.Range{
+				Filename: "main.tf",
+				Start:    tfjson.Pos{Line: 4, Column: 3, Byte: 60},
+				End:      tfjson.Pos{Line: 4, Column: 7, Byte: 64},
+			},
+		},
+		{
+			Severity: tfjson.DiagnosticSeverityWarning,
+			Summary:  "Deprecated attribute",
+		},
+	})
+
+	assert.Equal(t, []tfreconcilev1alpha1.Diagnostic{
+		{
+			Severity: "error",
+			Summary:  "Unsupported argument",
+			Detail:   `An argument named "nmae" is not expected here.`,
+			Range: &tfreconcilev1alpha1.SourceRange{
+				Filename: "main.tf",
+				Start:    tfreconcilev1alpha1.SourcePos{Line: 4, Column: 3},
+				End:      tfreconcilev1alpha1.SourcePos{Line: 4, Column: 7},
+			},
+		},
+		{
+			Severity: "warning",
+			Summary:  "Deprecated attribute",
+		},
+	}, diags)
+
+	assert.Equal(t, `main.tf:4,3: error: Unsupported argument; An argument named "nmae" is not expected here.`, diagnosticMessage(diags[0]))
+	assert.Equal(t, "warning: Deprecated attribute", diagnosticMessage(diags[1]))
+}
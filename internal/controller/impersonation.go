@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/client-go/rest"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultServiceAccountName is the service account workspaces without one act as.
+const defaultServiceAccountName = "default"
+
+// impersonatedClients are clients impersonating service accounts, keyed by the user name of the service
+// account, so each reuses its connections across runs.
+type impersonatedClients struct {
+	mu      sync.Mutex
+	clients map[string]client.Client
+	// newClient creates the client impersonating a user, from the RestConfig of the reconciler if nil.
+	newClient func(userName string) (client.Client, error)
+}
+
+// workspaceServiceAccount is the name of the service account the workspace acts as.
+func workspaceServiceAccount(ws tfreconcilev1alpha1.Workspace) string {
+	if ws.Spec.ServiceAccountName == "" {
+		return defaultServiceAccountName
+	}
+	return ws.Spec.ServiceAccountName
+}
+
+// referenceReader returns the reader of the Secrets and ConfigMaps the workspace references: a client
+// impersonating the service account of the workspace, or the default service account of its namespace if it
+// has none, so a workspace can only use what its service account may read. Impersonated reads go straight to
+// the API server, the cache of the operator would bypass the RBAC of the service account.
+func (r *WorkspaceReconciler) referenceReader(ws tfreconcilev1alpha1.Workspace) (client.Reader, error) {
+	serviceAccountName := workspaceServiceAccount(ws)
+	userName := fmt.Sprintf("system:serviceaccount:%s:%s", ws.Namespace, serviceAccountName)
+	r.impersonated.mu.Lock()
+	defer r.impersonated.mu.Unlock()
+	if c, ok := r.impersonated.clients[userName]; ok {
+		return c, nil
+	}
+
+	newClient := r.impersonated.newClient
+	if newClient == nil {
+		if r.RestConfig == nil {
+			return nil, fmt.Errorf("failed to impersonate service account %s: no rest config to impersonate with", serviceAccountName)
+		}
+		newClient = r.newImpersonatedClient
+	}
+	c, err := newClient(userName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client impersonating %s: %w", userName, err)
+	}
+
+	if r.impersonated.clients == nil {
+		r.impersonated.clients = map[string]client.Client{}
+	}
+	r.impersonated.clients[userName] = c
+	return c, nil
+}
+
+func (r *WorkspaceReconciler) newImpersonatedClient(userName string) (client.Client, error) {
+	cfg := rest.CopyConfig(r.RestConfig)
+	cfg.Impersonate = rest.ImpersonationConfig{UserName: userName}
+	return client.New(cfg, client.Options{Scheme: r.Scheme, Mapper: r.Client.RESTMapper()})
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newSecretEnvWorkspace(secrets ...string) tfreconcilev1alpha1.Workspace {
+	ws := tfreconcilev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws", Namespace: "default"},
+		Spec: tfreconcilev1alpha1.WorkspaceSpec{
+			ServiceAccountName: "terraform",
+			TFExec:             &tfreconcilev1alpha1.TFSpec{},
+		},
+	}
+	for _, secret := range secrets {
+		ws.Spec.TFExec.Env = append(ws.Spec.TFExec.Env, tfreconcilev1alpha1.EnvVar{
+			Name:         secret,
+			SecretKeyRef: &tfreconcilev1alpha1.SecretKeySelector{Name: secret, Key: "value"},
+		})
+	}
+	return ws
+}
+
+// impersonateWith makes r use c as the client impersonating any service account.
+func impersonateWith(r *WorkspaceReconciler, c client.Client) *WorkspaceReconciler {
+	r.impersonated.newClient = func(string) (client.Client, error) { return c, nil }
+	return r
+}
+
+// grant allows the given service account of the default namespace what rules allow.
+func grant(t *testing.T, c client.Client, serviceAccountName string, rules ...rbacv1.PolicyRule) {
+	t.Helper()
+	ctx := context.Background()
+	require.NoError(t, c.Create(ctx, &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: serviceAccountName, Namespace: "default"},
+		Rules:      rules,
+	}))
+	require.NoError(t, c.Create(ctx, &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: serviceAccountName, Namespace: "default"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: serviceAccountName},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: serviceAccountName, Namespace: "default"}},
+	}))
+}
+
+func TestReferenceReaderWithoutServiceAccount(t *testing.T) {
+	c := fakeclient.NewClientBuilder().WithScheme(newLifecycleScheme(t)).Build()
+	var impersonated []string
+	r := &WorkspaceReconciler{Client: c}
+	r.impersonated.newClient = func(userName string) (client.Client, error) {
+		impersonated = append(impersonated, userName)
+		return c, nil
+	}
+	ws := newSecretEnvWorkspace()
+	ws.Spec.ServiceAccountName = ""
+
+	_, err := r.referenceReader(ws)
+	require.NoError(t, err)
+	_, err = r.referenceReader(ws)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"system:serviceaccount:default:default"}, impersonated, "clients are reused")
+}
+
+func TestSpecEnvsWithoutReferences(t *testing.T) {
+	r := &WorkspaceReconciler{Client: fakeclient.NewClientBuilder().WithScheme(newLifecycleScheme(t)).Build()}
+	ws := newSecretEnvWorkspace()
+	ws.Spec.TFExec.Env = []tfreconcilev1alpha1.EnvVar{{Name: "TF_LOG", Value: "debug"}}
+
+	envs, err := r.specEnvs(context.Background(), ws)
+	require.NoError(t, err, "literal values need no client")
+	assert.Equal(t, map[string]string{"TF_LOG": "debug"}, envs)
+}
+
+func TestReferenceReaderWithoutRestConfig(t *testing.T) {
+	r := &WorkspaceReconciler{Client: fakeclient.NewClientBuilder().WithScheme(newLifecycleScheme(t)).Build()}
+
+	_, _, err := r.getEnvsForExecution(context.Background(), newSecretEnvWorkspace("allowed"))
+	assert.ErrorContains(t, err, "failed to impersonate service account terraform")
+}
+
+func TestSpecEnvsImpersonateServiceAccount(t *testing.T) {
+	scheme := newLifecycleScheme(t)
+	require.NoError(t, rbacv1.AddToScheme(scheme))
+	c, cfg := startTestEnvWithConfig(t, scheme)
+	ctx := context.Background()
+
+	require.NoError(t, c.Create(ctx, &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "terraform", Namespace: "default"}}))
+	grant(t, c, "terraform", rbacv1.PolicyRule{
+		APIGroups:     []string{""},
+		Resources:     []string{"secrets"},
+		ResourceNames: []string{"allowed"},
+		Verbs:         []string{"get"},
+	})
+	for _, name := range []string{"allowed", "denied"} {
+		require.NoError(t, c.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data:       map[string][]byte{"value": []byte(name)},
+		}))
+	}
+
+	r := &WorkspaceReconciler{Client: c, Scheme: scheme, RestConfig: cfg}
+
+	envs, err := r.specEnvs(ctx, newSecretEnvWorkspace("allowed"))
+	require.NoError(t, err)
+	assert.Equal(t, "allowed", envs["allowed"])
+
+	_, err = r.specEnvs(ctx, newSecretEnvWorkspace("allowed", "denied"))
+	require.Error(t, err)
+	assert.True(t, apierrors.IsForbidden(err), "reading a secret the service account may not read is forbidden: %v", err)
+
+	ws := newSecretEnvWorkspace("allowed")
+	ws.Spec.ServiceAccountName = ""
+	_, err = r.specEnvs(ctx, ws)
+	require.Error(t, err)
+	assert.True(t, apierrors.IsForbidden(err), "a workspace without a service account reads as the default service account: %v", err)
+}
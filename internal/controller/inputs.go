@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"lukaspj.io/kube-tf-reconciler/pkg/runner"
+)
+
+// volatileEnvs are environment variables whose values change on every reconcile without changing what
+// terraform does, such as paths to freshly written token files. They are left out of the inputs hash.
+var volatileEnvs = []string{
+	"AWS_WEB_IDENTITY_TOKEN_FILE",
+	"ARM_OIDC_TOKEN_FILE_PATH",
+	"GOOGLE_APPLICATION_CREDENTIALS",
+}
+
+// volatileAuthEnvs are the environment variables the authentication of the workspace sets to values that
+// differ on every run: dynamic secrets from Vault and paths and contents of service account tokens.
+func volatileAuthEnvs(ws tfreconcilev1alpha1.Workspace) []string {
+	auth := ws.Spec.Authentication
+	if auth == nil {
+		return nil
+	}
+
+	var names []string
+	if auth.Vault != nil {
+		for _, env := range auth.Vault.Env {
+			names = append(names, env.Name)
+		}
+	}
+	if auth.ServiceAccountToken != nil {
+		names = append(names, auth.ServiceAccountToken.FilePathEnv)
+		if auth.ServiceAccountToken.TokenEnv != "" {
+			names = append(names, auth.ServiceAccountToken.TokenEnv)
+		}
+	}
+	return names
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashEnv hashes the environment variables of a run in a stable order, leaving out volatileEnvs and the
+// given volatile variables.
+func hashEnv(envs map[string]string, volatile ...string) string {
+	h := sha256.New()
+	for _, k := range slices.Sorted(maps.Keys(envs)) {
+		if slices.Contains(volatileEnvs, k) || slices.Contains(volatile, k) {
+			continue
+		}
+		fmt.Fprintf(h, "%s=%d:%s\n", k, len(envs[k]), envs[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// workspaceInputs collects the inputs of a run of the workspace and hashes them together.
+func workspaceInputs(ws tfreconcilev1alpha1.Workspace, v *version.Version, render []byte, terraformRC string, envs map[string]string) tfreconcilev1alpha1.InputsStatus {
+	engine := ws.Spec.Engine
+	if engine == "" {
+		engine = runner.EngineTerraform
+	}
+
+	inputs := tfreconcilev1alpha1.InputsStatus{
+		Render:    hashBytes(render),
+		Env:       hashEnv(envs, volatileAuthEnvs(ws)...),
+		Engine:    engine,
+		Version:   v.String(),
+		AutoApply: ws.Spec.AutoApply,
+	}
+	if terraformRC != "" {
+		inputs.TerraformRC = hashBytes([]byte(terraformRC))
+	}
+
+	inputs.Hash = hashBytes(fmt.Appendf(nil, "%s\n%s\n%s\n%s\n%s\n%t\n",
+		inputs.Render, inputs.Env, inputs.TerraformRC, inputs.Engine, inputs.Version, inputs.AutoApply))
+	return inputs
+}
+
+// changedInputs names the inputs that differ between the previous run and the current one.
+func changedInputs(previous *tfreconcilev1alpha1.InputsStatus, current tfreconcilev1alpha1.InputsStatus) []string {
+	if previous == nil {
+		return nil
+	}
+
+	var changed []string
+	if previous.Render != current.Render {
+		changed = append(changed, "render")
+	}
+	if previous.Env != current.Env {
+		changed = append(changed, "env")
+	}
+	if previous.TerraformRC != current.TerraformRC {
+		changed = append(changed, "terraformRC")
+	}
+	if previous.Engine != current.Engine {
+		changed = append(changed, "engine")
+	}
+	if previous.Version != current.Version {
+		changed = append(changed, "version")
+	}
+	if previous.AutoApply != current.AutoApply {
+		changed = append(changed, "autoApply")
+	}
+	return changed
+}
+
+// runReason explains why a run was not skipped.
+func runReason(previous *tfreconcilev1alpha1.InputsStatus, current tfreconcilev1alpha1.InputsStatus) (string, string) {
+	if previous == nil {
+		return "FirstRun", "no previous run recorded"
+	}
+	if changed := changedInputs(previous, current); len(changed) > 0 {
+		return "InputsChanged", fmt.Sprintf("changed inputs: %s", strings.Join(changed, ", "))
+	}
+	return "RefreshDue", "inputs unchanged, refresh is due"
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+func TestWorkspaceInputs(t *testing.T) {
+	ws := tfreconcilev1alpha1.Workspace{}
+	v := version.Must(version.NewVersion("1.11.2"))
+	render := []byte(`module "m" {}`)
+	envs := map[string]string{"A": "1", "AWS_WEB_IDENTITY_TOKEN_FILE": "/tmp/token-1"}
+
+	base := workspaceInputs(ws, v, render, "", envs)
+	assert.Equal(t, "terraform", base.Engine)
+	assert.Equal(t, "1.11.2", base.Version)
+
+	envs["AWS_WEB_IDENTITY_TOKEN_FILE"] = "/tmp/token-2"
+	assert.Equal(t, base, workspaceInputs(ws, v, render, "", envs), "volatile envs must not change the hash")
+
+	changedEnv := workspaceInputs(ws, v, render, "", map[string]string{"A": "2"})
+	assert.NotEqual(t, base.Hash, changedEnv.Hash)
+	assert.Equal(t, []string{"env"}, changedInputs(&base, changedEnv))
+
+	ws.Spec.AutoApply = true
+	changed := workspaceInputs(ws, version.Must(version.NewVersion("1.11.3")), []byte(`module "n" {}`), "plugin_cache_dir = \"/cache\"", envs)
+	assert.NotEqual(t, base.Hash, changed.Hash)
+	assert.Equal(t, []string{"render", "terraformRC", "version", "autoApply"}, changedInputs(&base, changed))
+
+	reason, message := runReason(&base, changed)
+	assert.Equal(t, "InputsChanged", reason)
+	assert.Equal(t, "changed inputs: render, terraformRC, version, autoApply", message)
+
+	reason, _ = runReason(nil, base)
+	assert.Equal(t, "FirstRun", reason)
+	reason, _ = runReason(&base, base)
+	assert.Equal(t, "RefreshDue", reason)
+}
+
+func TestHashEnvIsOrderIndependent(t *testing.T) {
+	assert.Equal(t,
+		hashEnv(map[string]string{"A": "1", "B": "2"}),
+		hashEnv(map[string]string{"B": "2", "A": "1"}))
+	assert.NotEqual(t,
+		hashEnv(map[string]string{"A": "1=B"}),
+		hashEnv(map[string]string{"A": "1", "B": ""}))
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"lukaspj.io/kube-tf-reconciler/pkg/runner"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// tokenFilePatterns match the temporary token and credentials files written for workspace authentication,
+// which are left behind when their cleanup at the end of a reconcile fails.
+var tokenFilePatterns = []string{"aws-token-*", "azure-token-*", "gcp-token-*", "gcp-credentials-*", "sa-token-*"}
+
+// Janitor periodically removes what deleted workspaces and interrupted operations left on disk and in the
+// workspace storage, and reports the disk usage of the operator.
+//
+// It is a manager.Runnable.
+type Janitor struct {
+	Client client.Reader
+	Tf     *runner.Exec
+	// Interval is how often the janitor runs.
+	Interval time.Duration
+	// MinAge is how long anything must have been left untouched before it is removed, which keeps the janitor
+	// from racing reconciles that are just creating it.
+	MinAge time.Duration
+}
+
+// Start runs the janitor every Interval until ctx is cancelled.
+func (j *Janitor) Start(ctx context.Context) error {
+	log := logf.FromContext(ctx).WithName("janitor")
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := j.collect(ctx)
+		if err != nil {
+			log.Error(err, "failed to collect garbage")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection is true, only the leader runs workspaces.
+func (j *Janitor) NeedLeaderElection() bool {
+	return true
+}
+
+func (j *Janitor) collect(ctx context.Context) error {
+	var list tfreconcilev1alpha1.WorkspaceList
+	err := j.Client.List(ctx, &list)
+	if err != nil {
+		return fmt.Errorf("failed to list workspaces: %w", err)
+	}
+
+	inUse := runner.InUse{Workspaces: map[string]bool{}, Versions: map[string]bool{}}
+	for _, ws := range list.Items {
+		inUse.Workspaces[types.NamespacedName{Namespace: ws.Namespace, Name: ws.Name}.String()] = true
+		if ws.Status.ResolvedVersion != "" {
+			engine := ws.Spec.Engine
+			if engine == "" {
+				engine = runner.EngineTerraform
+			}
+			inUse.Versions[engine+"/"+ws.Status.ResolvedVersion] = true
+		}
+	}
+
+	cutoff := time.Now().Add(-j.MinAge)
+	return errors.Join(
+		j.Tf.CollectGarbage(inUse, cutoff),
+		j.Tf.CollectSnapshots(ctx, inUse, cutoff),
+		runner.RemoveStaleFiles(os.TempDir(), tokenFilePatterns, cutoff),
+		j.Tf.ReportDiskUsage(),
+	)
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"lukaspj.io/kube-tf-reconciler/pkg/runner"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestJanitorCollect(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, tfreconcilev1alpha1.AddToScheme(scheme))
+
+	ws := &tfreconcilev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "live", Namespace: "default"},
+		Spec:       tfreconcilev1alpha1.WorkspaceSpec{Engine: runner.EngineOpenTofu},
+		Status:     tfreconcilev1alpha1.WorkspaceStatus{ResolvedVersion: "1.9.0"},
+	}
+	tf := runner.New(t.TempDir())
+	j := &Janitor{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(ws).Build(),
+		Tf:     tf,
+		MinAge: time.Hour,
+	}
+
+	tofuDir := tf.Installers[runner.EngineOpenTofu].(*runner.TofuInstaller).InstallDir
+	live := filepath.Join(tf.WorkspacesDir, "default", "live")
+	deleted := filepath.Join(tf.WorkspacesDir, "default", "deleted")
+	install := filepath.Join(tofuDir, "1.9.0")
+	old := time.Now().Add(-2 * time.Hour)
+	for _, dir := range []string{live, deleted, install} {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.Chtimes(dir, old, old))
+	}
+
+	require.NoError(t, j.collect(context.Background()))
+	assert.DirExists(t, live)
+	assert.DirExists(t, install)
+	assert.NoDirExists(t, deleted)
+}
@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"lukaspj.io/kube-tf-reconciler/internal/testutils"
+	"lukaspj.io/kube-tf-reconciler/pkg/runner"
+	"lukaspj.io/kube-tf-reconciler/pkg/runner/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newLifecycleScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, tfreconcilev1alpha1.AddToScheme(scheme))
+	return scheme
+}
+
+// startTestEnv starts an API server with the CRDs installed for the duration of the test and returns a
+// client of it. The test is skipped if the envtest binaries are not available.
+func startTestEnv(t *testing.T, scheme *runtime.Scheme) client.Client {
+	t.Helper()
+	c, _ := startTestEnvWithConfig(t, scheme)
+	return c
+}
+
+// startTestEnvWithConfig is startTestEnv which also returns the admin config of the API server.
+func startTestEnvWithConfig(t *testing.T, scheme *runtime.Scheme) (client.Client, *rest.Config) {
+	t.Helper()
+	assets := testutils.GetFirstFoundEnvTestBinaryDir()
+	if assets == "" && os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("envtest binaries not found")
+	}
+
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "crds")},
+		BinaryAssetsDirectory: assets,
+		ErrorIfCRDPathMissing: true,
+		Scheme:                scheme,
+	}
+	cfg, err := testEnv.Start()
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, testEnv.Stop()) })
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	require.NoError(t, err)
+	return c, cfg
+}
+
+func newLifecycleWorkspace() *tfreconcilev1alpha1.Workspace {
+	ws := newWorkspace()
+	ws.Spec.AutoApply = true
+	return ws
+}
+
+// testLifecycle takes the workspace through creation, apply, an idle reconcile and deletion, which leaves the
+// workspace applied with the outputs {"vpc_id": "vpc-123"} and then destroyed.
+func testLifecycle(t *testing.T, c client.Client, r *WorkspaceReconciler, ws *tfreconcilev1alpha1.Workspace, commands func() []string) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: ws.Namespace, Name: ws.Name}
+	req := reconcile.Request{NamespacedName: key}
+	dir := r.Tf.WorkspaceDir(*ws)
+
+	// The finalizer is added before anything is planned
+	result, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.True(t, result.Requeue)
+	require.NoError(t, c.Get(ctx, key, ws))
+	assert.Contains(t, ws.Finalizers, workspaceFinalizer)
+	assert.Equal(t, []string{"init", "validate"}, commands())
+
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.NoError(t, c.Get(ctx, key, ws))
+	assert.Equal(t, []string{"init", "validate", "validate", "plan", "show", "show", "apply", "output"}, commands())
+	assert.Equal(t, ws.Generation, ws.Status.ObservedGeneration)
+	assert.True(t, ws.Status.ValidRender)
+	require.NotNil(t, ws.Status.PlanSummary)
+	assert.Equal(t, 1, ws.Status.PlanSummary.Add)
+	assert.Contains(t, ws.Status.LatestPlan, "1 to add")
+	require.Len(t, ws.Status.Outputs, 1)
+	assert.Equal(t, "vpc_id", ws.Status.Outputs[0].Name)
+	assert.JSONEq(t, `"vpc-123"`, string(ws.Status.Outputs[0].Value.Raw))
+	assert.FileExists(t, filepath.Join(dir, "terraform.tfstate"))
+	assert.NoFileExists(t, filepath.Join(dir, "plan.out"), "only kept artifacts outlive the run")
+
+	// Nothing changed, so nothing runs
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Len(t, commands(), 8)
+
+	require.NoError(t, c.Delete(ctx, ws))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "destroy", commands()[len(commands())-1])
+	assert.True(t, apierrors.IsNotFound(c.Get(ctx, key, ws)))
+	assert.NoDirExists(t, dir)
+}
+
+func TestWorkspaceLifecycle(t *testing.T) {
+	scheme := newLifecycleScheme(t)
+	ws := newLifecycleWorkspace()
+	// The API server would have set it on creation
+	ws.Generation = 1
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(ws).WithStatusSubresource(ws).Build()
+
+	tf := &fake.Terraform{
+		Changes: true,
+		Outputs: map[string]tfexec.OutputMeta{
+			"vpc_id": {Type: json.RawMessage(`"string"`), Value: json.RawMessage(`"vpc-123"`)},
+		},
+	}
+	r := &WorkspaceReconciler{
+		Client:   c,
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(100),
+		Tf:       runner.New(t.TempDir()),
+	}
+	r.Tf.NewTerraform = tf.New
+	r.Tf.Installers[runner.EngineTerraform] = &fake.Installer{}
+
+	testLifecycle(t, c, r, ws, tf.Commands)
+}
+
+func TestWorkspaceLifecycleEnvtest(t *testing.T) {
+	scheme := newLifecycleScheme(t)
+	c := startTestEnv(t, scheme)
+
+	binDir := t.TempDir()
+	require.NoError(t, testutils.BuildFakeTerraform(binDir, "1.11.2"))
+	logPath := filepath.Join(t.TempDir(), "terraform.log")
+
+	ws := newLifecycleWorkspace()
+	ws.Spec.TFExec = &tfreconcilev1alpha1.TFSpec{Env: []tfreconcilev1alpha1.EnvVar{
+		{Name: "FAKE_TERRAFORM_PLAN_CHANGES", Value: "true"},
+		{Name: "FAKE_TERRAFORM_OUTPUTS", Value: `{"vpc_id": "vpc-123"}`},
+		{Name: "FAKE_TERRAFORM_LOG", Value: logPath},
+	}}
+	require.NoError(t, c.Create(context.Background(), ws))
+
+	r := &WorkspaceReconciler{
+		Client:   c,
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(100),
+		Tf:       runner.New(t.TempDir()),
+	}
+	r.Tf.SetTerraformDir(binDir)
+
+	// terraform-exec checks the version before some commands, which is not part of the lifecycle
+	commands := func() []string {
+		content, err := os.ReadFile(logPath)
+		require.NoError(t, err)
+		var commands []string
+		for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+			if line != "version" {
+				commands = append(commands, line)
+			}
+		}
+		return commands
+	}
+	testLifecycle(t, c, r, ws, commands)
+}
+
+func TestWorkspaceInvalidRender(t *testing.T) {
+	ctx := context.Background()
+	scheme := newLifecycleScheme(t)
+	ws := newLifecycleWorkspace()
+	ws.Generation = 1
+	ws.Finalizers = []string{workspaceFinalizer}
+	ws.Annotations = map[string]string{tfreconcilev1alpha1.AnnotationUpgradeProviders: "true"}
+	ws.Status.LatestPlan = "1 to add"
+	ws.Status.PlanSummary = &tfreconcilev1alpha1.PlanSummary{Add: 1}
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(ws).WithStatusSubresource(ws).Build()
+
+	tf := &fake.Terraform{
+		Diagnostics: []tfjson.Diagnostic{{Severity: tfjson.DiagnosticSeverityError, Summary: "Unsupported argument"}},
+	}
+	r := &WorkspaceReconciler{
+		Client:   c,
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(100),
+		Tf:       runner.New(t.TempDir()),
+	}
+	r.Tf.NewTerraform = tf.New
+	r.Tf.Installers[runner.EngineTerraform] = &fake.Installer{}
+
+	key := types.NamespacedName{Namespace: ws.Namespace, Name: ws.Name}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"init", "validate"}, tf.Commands())
+
+	require.NoError(t, c.Get(ctx, key, ws))
+	assert.False(t, ws.Status.ValidRender)
+	assert.Empty(t, ws.Status.LatestPlan, "the plan of an earlier render is stale")
+	assert.Nil(t, ws.Status.PlanSummary)
+	assert.True(t, meta.IsStatusConditionTrue(ws.Status.Conditions, tfreconcilev1alpha1.ConditionPlanSkipped))
+	assert.Contains(t, ws.Annotations, tfreconcilev1alpha1.AnnotationUpgradeProviders, "no plan reported the upgrade yet")
+	resourceVersion := ws.ResourceVersion
+
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"init", "validate"}, tf.Commands(), "init is not run again until the inputs change")
+	require.NoError(t, c.Get(ctx, key, ws))
+	assert.Equal(t, resourceVersion, ws.ResourceVersion, "the status is left as it is")
+}
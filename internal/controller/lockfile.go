@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"lukaspj.io/kube-tf-reconciler/pkg/inspect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// lockConfigMapName is the name of the ConfigMap the dependency lock file of a workspace is persisted in.
+func lockConfigMapName(ws tfreconcilev1alpha1.Workspace) string {
+	return ws.Name + "-tf-lock"
+}
+
+// lockConfigMap returns the ConfigMap holding the dependency lock file of the workspace, or nil if there is
+// none yet. A ConfigMap of the same name the operator didn't create for the workspace is an error, rather
+// than something to read a lock file from or to overwrite.
+func (r *WorkspaceReconciler) lockConfigMap(ctx context.Context, ws *tfreconcilev1alpha1.Workspace) (*v1.ConfigMap, error) {
+	var cm v1.ConfigMap
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: ws.Namespace, Name: lockConfigMapName(*ws)}, &cm)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lock file configmap: %w", err)
+	}
+
+	if cm.Labels[tfreconcilev1alpha1.LabelLockFileWorkspace] != ws.Name || !metav1.IsControlledBy(&cm, ws) {
+		return nil, fmt.Errorf("configmap %s already exists and is not the lock file of workspace %s", cm.Name, ws.Name)
+	}
+
+	return &cm, nil
+}
+
+// restoreLockFile writes the persisted dependency lock file of the workspace into its directory and returns
+// its content, or nil if none has been persisted yet.
+func (r *WorkspaceReconciler) restoreLockFile(ctx context.Context, ws tfreconcilev1alpha1.Workspace, workspaceDir string) ([]byte, error) {
+	cm, err := r.lockConfigMap(ctx, &ws)
+	if err != nil || cm == nil {
+		return nil, err
+	}
+
+	content, ok := cm.Data[inspect.LockFileName]
+	if !ok {
+		return nil, nil
+	}
+
+	err = os.WriteFile(filepath.Join(workspaceDir, inspect.LockFileName), []byte(content), 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore lock file: %w", err)
+	}
+
+	return []byte(content), nil
+}
+
+// persistLockFile stores the dependency lock file of the workspace directory in a ConfigMap owned by the
+// workspace, unless it is unchanged from previous. It returns the current content.
+func (r *WorkspaceReconciler) persistLockFile(ctx context.Context, ws *tfreconcilev1alpha1.Workspace, workspaceDir string, previous []byte) ([]byte, error) {
+	content, err := os.ReadFile(filepath.Join(workspaceDir, inspect.LockFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+	if bytes.Equal(content, previous) {
+		return content, nil
+	}
+
+	cm, err := r.lockConfigMap(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+	if cm != nil {
+		cm.Data = map[string]string{inspect.LockFileName: string(content)}
+		err = r.Client.Update(ctx, cm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to persist lock file: %w", err)
+		}
+		return content, nil
+	}
+
+	cm = &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ws.Namespace,
+			Name:      lockConfigMapName(*ws),
+			Labels:    map[string]string{tfreconcilev1alpha1.LabelLockFileWorkspace: ws.Name},
+		},
+		Data: map[string]string{inspect.LockFileName: string(content)},
+	}
+	err = controllerutil.SetControllerReference(ws, cm, r.Scheme)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set owner of lock file configmap: %w", err)
+	}
+	err = r.Client.Create(ctx, cm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to persist lock file: %w", err)
+	}
+
+	return content, nil
+}
+
+// lockFileProviderChanges describes how the providers of the lock file changed.
+func lockFileProviderChanges(before, after []byte) ([]string, error) {
+	oldProviders, err := inspect.LockedProviders(before)
+	if err != nil {
+		return nil, err
+	}
+	newProviders, err := inspect.LockedProviders(after)
+	if err != nil {
+		return nil, err
+	}
+
+	return inspect.ProviderChanges(oldProviders, newProviders), nil
+}
+
+func upgradeRequested(ws tfreconcilev1alpha1.Workspace) bool {
+	_, ok := ws.Annotations[tfreconcilev1alpha1.AnnotationUpgradeProviders]
+	return ok
+}
+
+// clearUpgradeRequest removes the provider upgrade annotation once the upgrade has been carried out.
+func (r *WorkspaceReconciler) clearUpgradeRequest(ctx context.Context, ws *tfreconcilev1alpha1.Workspace) error {
+	if !upgradeRequested(*ws) {
+		return nil
+	}
+
+	return r.removeAnnotations(ctx, ws, tfreconcilev1alpha1.AnnotationUpgradeProviders)
+}
+
+// removeAnnotations removes request annotations from the workspace once they have been acted upon.
+func (r *WorkspaceReconciler) removeAnnotations(ctx context.Context, ws *tfreconcilev1alpha1.Workspace, keys ...string) error {
+	for _, key := range keys {
+		delete(ws.Annotations, key)
+	}
+
+	err := r.Update(ctx, ws)
+	if err != nil {
+		return fmt.Errorf("failed to remove annotations %v: %w", keys, err)
+	}
+
+	return nil
+}
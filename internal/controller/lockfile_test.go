@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"lukaspj.io/kube-tf-reconciler/pkg/inspect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLockFilePersistence(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, tfreconcilev1alpha1.AddToScheme(scheme))
+
+	ws := &tfreconcilev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws", Namespace: "default", UID: "uid"},
+	}
+	r := &WorkspaceReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(ws).Build(),
+		Scheme: scheme,
+	}
+	ctx := context.Background()
+
+	first := t.TempDir()
+	restored, err := r.restoreLockFile(ctx, *ws, first)
+	require.NoError(t, err)
+	assert.Nil(t, restored, "nothing has been persisted yet")
+
+	lock := []byte(`provider "registry.terraform.io/hashicorp/null" {
+  version = "3.2.3"
+}
+`)
+	require.NoError(t, os.WriteFile(filepath.Join(first, inspect.LockFileName), lock, 0644))
+	persisted, err := r.persistLockFile(ctx, ws, first, nil)
+	require.NoError(t, err)
+	assert.Equal(t, lock, persisted)
+
+	var cm v1.ConfigMap
+	require.NoError(t, r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "ws-tf-lock"}, &cm))
+	require.Len(t, cm.OwnerReferences, 1)
+	assert.Equal(t, "ws", cm.OwnerReferences[0].Name)
+	assert.Equal(t, "ws", cm.Labels[tfreconcilev1alpha1.LabelLockFileWorkspace])
+
+	// A fresh directory, e.g. after a pod restart, gets the persisted lock file back
+	second := t.TempDir()
+	restored, err = r.restoreLockFile(ctx, *ws, second)
+	require.NoError(t, err)
+	assert.Equal(t, lock, restored)
+	content, err := os.ReadFile(filepath.Join(second, inspect.LockFileName))
+	require.NoError(t, err)
+	assert.Equal(t, lock, content)
+
+	upgraded := []byte(`provider "registry.terraform.io/hashicorp/null" {
+  version = "3.2.4"
+}
+`)
+	require.NoError(t, os.WriteFile(filepath.Join(second, inspect.LockFileName), upgraded, 0644))
+	_, err = r.persistLockFile(ctx, ws, second, restored)
+	require.NoError(t, err)
+	require.NoError(t, r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "ws-tf-lock"}, &cm))
+	assert.Equal(t, string(upgraded), cm.Data[inspect.LockFileName])
+
+	changes, err := lockFileProviderChanges(restored, upgraded)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"registry.terraform.io/hashicorp/null: 3.2.3 -> 3.2.4"}, changes)
+}
+
+func TestLockFileRefusesForeignConfigMap(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, tfreconcilev1alpha1.AddToScheme(scheme))
+
+	ws := &tfreconcilev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws", Namespace: "default", UID: "uid"},
+	}
+	other := &tfreconcilev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default", UID: "other-uid"},
+	}
+	// One the user created with the same name, and one that claims to be a lock file but isn't owned by ws
+	unowned := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws-tf-lock", Namespace: "default"},
+		Data:       map[string]string{"app.conf": "keep me"},
+	}
+	labelled := unowned.DeepCopy()
+	labelled.Labels = map[string]string{tfreconcilev1alpha1.LabelLockFileWorkspace: "ws"}
+	controller := true
+	labelled.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: tfreconcilev1alpha1.GroupVersion.String(), Kind: "Workspace", Name: other.Name, UID: other.UID, Controller: &controller,
+	}}
+	lock := []byte(`provider "registry.terraform.io/hashicorp/null" {}` + "\n")
+
+	for name, cm := range map[string]*v1.ConfigMap{"unowned": unowned, "owned by another workspace": labelled} {
+		t.Run(name, func(t *testing.T) {
+			r := &WorkspaceReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(ws, cm).Build(),
+				Scheme: scheme,
+			}
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			_, err := r.restoreLockFile(ctx, *ws, dir)
+			assert.ErrorContains(t, err, "not the lock file of workspace ws")
+			assert.NoFileExists(t, filepath.Join(dir, inspect.LockFileName))
+
+			require.NoError(t, os.WriteFile(filepath.Join(dir, inspect.LockFileName), lock, 0644))
+			_, err = r.persistLockFile(ctx, ws, dir, nil)
+			assert.ErrorContains(t, err, "not the lock file of workspace ws")
+
+			var stored v1.ConfigMap
+			require.NoError(t, r.Client.Get(ctx, client.ObjectKeyFromObject(cm), &stored))
+			assert.Equal(t, map[string]string{"app.conf": "keep me"}, stored.Data)
+		})
+	}
+}
+
+func TestClearUpgradeRequest(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, tfreconcilev1alpha1.AddToScheme(scheme))
+
+	ws := &tfreconcilev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "ws",
+			Namespace:   "default",
+			Annotations: map[string]string{tfreconcilev1alpha1.AnnotationUpgradeProviders: "true"},
+		},
+	}
+	r := &WorkspaceReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(ws).Build()}
+	assert.True(t, upgradeRequested(*ws))
+
+	require.NoError(t, r.clearUpgradeRequest(context.Background(), ws))
+
+	var stored tfreconcilev1alpha1.Workspace
+	require.NoError(t, r.Client.Get(context.Background(), client.ObjectKeyFromObject(ws), &stored))
+	assert.False(t, upgradeRequested(stored))
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+// summarizePlan counts the resource changes of a plan the same way terraform does, and lists the imports,
+// moves and forgets it contains.
+func summarizePlan(plan *tfjson.Plan) tfreconcilev1alpha1.PlanSummary {
+	var summary tfreconcilev1alpha1.PlanSummary
+	for _, rc := range plan.ResourceChanges {
+		if rc.Change == nil {
+			continue
+		}
+
+		actions := rc.Change.Actions
+		switch {
+		case actions.Replace():
+			summary.Add++
+			summary.Destroy++
+		case actions.Create():
+			summary.Add++
+		case actions.Update():
+			summary.Change++
+		case actions.Delete():
+			summary.Destroy++
+		case actions.Forget():
+			summary.Forgets = append(summary.Forgets, rc.Address)
+		}
+
+		if rc.Change.Importing != nil {
+			summary.Imports = append(summary.Imports, rc.Address)
+		}
+		if rc.PreviousAddress != "" && rc.PreviousAddress != rc.Address {
+			summary.Moves = append(summary.Moves, fmt.Sprintf("%s -> %s", rc.PreviousAddress, rc.Address))
+		}
+	}
+
+	return summary
+}
+
+// planSummaryMessage formats the summary like the "Plan:" line of terraform.
+func planSummaryMessage(summary tfreconcilev1alpha1.PlanSummary) string {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "%d to add, %d to change, %d to destroy", summary.Add, summary.Change, summary.Destroy)
+	if len(summary.Imports) > 0 {
+		fmt.Fprintf(&msg, ", %d to import (%s)", len(summary.Imports), strings.Join(summary.Imports, ", "))
+	}
+	if len(summary.Moves) > 0 {
+		fmt.Fprintf(&msg, ", %d to move (%s)", len(summary.Moves), strings.Join(summary.Moves, ", "))
+	}
+	if len(summary.Forgets) > 0 {
+		fmt.Fprintf(&msg, ", %d to forget (%s)", len(summary.Forgets), strings.Join(summary.Forgets, ", "))
+	}
+	if len(summary.ProviderChanges) > 0 {
+		fmt.Fprintf(&msg, ", providers upgraded (%s)", strings.Join(summary.ProviderChanges, ", "))
+	}
+
+	return msg.String()
+}
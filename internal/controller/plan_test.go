@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+func TestSummarizePlan(t *testing.T) {
+	plan := &tfjson.Plan{
+		ResourceChanges: []*tfjson.ResourceChange{
+			{
+				Address: "module.m.aws_vpc.this",
+				Change:  &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionCreate}},
+			},
+			{
+				Address: "module.m.aws_subnet.this",
+				Change:  &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionDelete, tfjson.ActionCreate}},
+			},
+			{
+				Address: "module.m.aws_s3_bucket.logs",
+				Change: &tfjson.Change{
+					Actions:   tfjson.Actions{tfjson.ActionNoop},
+					Importing: &tfjson.Importing{ID: "logs"},
+				},
+			},
+			{
+				Address:         "module.m.aws_iam_role.new",
+				PreviousAddress: "module.m.aws_iam_role.old",
+				Change:          &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionUpdate}},
+			},
+			{
+				Address: "module.m.aws_eip.nat",
+				Change:  &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionForget}},
+			},
+		},
+	}
+
+	summary := summarizePlan(plan)
+	assert.Equal(t, tfreconcilev1alpha1.PlanSummary{
+		Add:     2,
+		Change:  1,
+		Destroy: 1,
+		Imports: []string{"module.m.aws_s3_bucket.logs"},
+		Moves:   []string{"module.m.aws_iam_role.old -> module.m.aws_iam_role.new"},
+		Forgets: []string{"module.m.aws_eip.nat"},
+	}, summary)
+	assert.Equal(t, "2 to add, 1 to change, 1 to destroy, "+
+		"1 to import (module.m.aws_s3_bucket.logs), "+
+		"1 to move (module.m.aws_iam_role.old -> module.m.aws_iam_role.new), "+
+		"1 to forget (module.m.aws_eip.nat)", planSummaryMessage(summary))
+}
+
+func TestPlanSummaryMessageProviderChanges(t *testing.T) {
+	summary := tfreconcilev1alpha1.PlanSummary{
+		ProviderChanges: []string{"registry.terraform.io/hashicorp/aws: 5.0.0 -> 5.1.0"},
+	}
+	assert.Equal(t, "0 to add, 0 to change, 0 to destroy, "+
+		"providers upgraded (registry.terraform.io/hashicorp/aws: 5.0.0 -> 5.1.0)", planSummaryMessage(summary))
+}
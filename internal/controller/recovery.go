@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"lukaspj.io/kube-tf-reconciler/pkg/runner"
+)
+
+// detectRecovery combines what an interrupted run left in the workspace directory with a lock terraform
+// reported in an earlier run. It returns nil if there is nothing to recover.
+func (r *WorkspaceReconciler) detectRecovery(ws tfreconcilev1alpha1.Workspace) (*tfreconcilev1alpha1.StateRecoveryStatus, error) {
+	recovery, err := r.Tf.DetectRecovery(r.Tf.WorkspaceDir(ws))
+	if err != nil {
+		return nil, err
+	}
+
+	if ws.Status.StateRecovery != nil && ws.Status.StateRecovery.Lock != nil && (recovery == nil || recovery.Lock == nil) {
+		if recovery == nil {
+			recovery = &tfreconcilev1alpha1.StateRecoveryStatus{}
+		}
+		recovery.Lock = ws.Status.StateRecovery.Lock
+	}
+
+	return recovery, nil
+}
+
+func recoveryRequested(ws tfreconcilev1alpha1.Workspace) bool {
+	_, push := ws.Annotations[tfreconcilev1alpha1.AnnotationPushErroredState]
+	_, unlock := ws.Annotations[tfreconcilev1alpha1.AnnotationForceUnlock]
+	return push || unlock
+}
+
+// clearRecoveryRequest removes the recovery annotations.
+func (r *WorkspaceReconciler) clearRecoveryRequest(ctx context.Context, ws *tfreconcilev1alpha1.Workspace) error {
+	return r.removeAnnotations(ctx, ws, tfreconcilev1alpha1.AnnotationPushErroredState, tfreconcilev1alpha1.AnnotationForceUnlock)
+}
+
+// recoveryMessage explains what needs recovering and how.
+func recoveryMessage(recovery tfreconcilev1alpha1.StateRecoveryStatus) string {
+	var msgs []string
+	if recovery.ErroredState {
+		msgs = append(msgs, fmt.Sprintf("an interrupted run left %s, annotate with %s to push it to the backend",
+			runner.ErroredStateFile, tfreconcilev1alpha1.AnnotationPushErroredState))
+	}
+	if lock := recovery.Lock; lock != nil {
+		msgs = append(msgs, fmt.Sprintf("the state is locked by %q for %s since %s, annotate with %s=%s to remove the lock",
+			lock.Who, lock.Operation, lock.Created, tfreconcilev1alpha1.AnnotationForceUnlock, lock.ID))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// requireRecovery records that the workspace must be recovered before it is run again.
+func (r *WorkspaceReconciler) requireRecovery(ctx context.Context, ws *tfreconcilev1alpha1.Workspace, recovery *tfreconcilev1alpha1.StateRecoveryStatus) error {
+	if meta.IsStatusConditionTrue(ws.Status.Conditions, tfreconcilev1alpha1.ConditionStateRecoveryRequired) &&
+		equality.Semantic.DeepEqual(ws.Status.StateRecovery, recovery) {
+		return nil
+	}
+
+	message := recoveryMessage(*recovery)
+	r.Recorder.Eventf(ws, v1.EventTypeWarning, TFRecoveryEventReason, "%s", message)
+	ws.Status.StateRecovery = recovery
+	meta.SetStatusCondition(&ws.Status.Conditions, metav1.Condition{
+		Type:               tfreconcilev1alpha1.ConditionStateRecoveryRequired,
+		Status:             metav1.ConditionTrue,
+		Reason:             "InterruptedRun",
+		Message:            message,
+		ObservedGeneration: ws.Generation,
+	})
+	return r.Client.Status().Update(ctx, ws)
+}
+
+// recoverAfterFailure checks whether a failed terraform command left the state locked or errored in the run
+// directory dir, in which case the workspace is marked as requiring recovery and true is returned.
+func (r *WorkspaceReconciler) recoverAfterFailure(ctx context.Context, ws *tfreconcilev1alpha1.Workspace, dir string, cmdErr error) (bool, error) {
+	recovery, err := r.Tf.DetectRecovery(dir)
+	if err != nil {
+		return false, err
+	}
+	if lock := runner.ParseLockError(cmdErr); lock != nil {
+		if recovery == nil {
+			recovery = &tfreconcilev1alpha1.StateRecoveryStatus{}
+		}
+		recovery.Lock = lock
+	}
+	if recovery == nil {
+		return false, nil
+	}
+
+	return true, r.requireRecovery(ctx, ws, recovery)
+}
+
+// recoverState carries out the recovery actions requested through annotations and removes the annotations.
+// It returns what is left to recover, or nil if the workspace has fully recovered.
+func (r *WorkspaceReconciler) recoverState(ctx context.Context, ws *tfreconcilev1alpha1.Workspace, tf runner.Terraform, recovery *tfreconcilev1alpha1.StateRecoveryStatus) (*tfreconcilev1alpha1.StateRecoveryStatus, error) {
+	remaining := recovery.DeepCopy()
+
+	if _, ok := ws.Annotations[tfreconcilev1alpha1.AnnotationPushErroredState]; ok && remaining.ErroredState {
+		err := r.Tf.PushErroredState(ctx, tf)
+		if err != nil {
+			return nil, err
+		}
+		r.Recorder.Eventf(ws, v1.EventTypeNormal, TFRecoveryEventReason, "Pushed %s to the backend", runner.ErroredStateFile)
+		remaining.ErroredState = false
+	}
+
+	if lockID := ws.Annotations[tfreconcilev1alpha1.AnnotationForceUnlock]; lockID != "" && remaining.Lock != nil {
+		err := r.Tf.ForceUnlock(ctx, tf, lockID)
+		if err != nil {
+			return nil, err
+		}
+		r.Recorder.Eventf(ws, v1.EventTypeNormal, TFRecoveryEventReason, "Removed state lock %s", lockID)
+		remaining.Lock = nil
+	}
+
+	err := r.clearRecoveryRequest(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	if remaining.ErroredState || remaining.Lock != nil {
+		return remaining, nil
+	}
+
+	ws.Status.StateRecovery = nil
+	meta.SetStatusCondition(&ws.Status.Conditions, metav1.Condition{
+		Type:               tfreconcilev1alpha1.ConditionStateRecoveryRequired,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Recovered",
+		Message:            "the state has been recovered",
+		ObservedGeneration: ws.Generation,
+	})
+	return nil, nil
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"lukaspj.io/kube-tf-reconciler/pkg/runner"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRecoveryMessage(t *testing.T) {
+	assert.Equal(t,
+		"an interrupted run left errored.tfstate, annotate with tf-reconcile.lukaspj.io/push-errored-state to push it to the backend; "+
+			`the state is locked by "krec@pod" for OperationTypeApply since 2026-10-19, annotate with tf-reconcile.lukaspj.io/force-unlock=1234 to remove the lock`,
+		recoveryMessage(tfreconcilev1alpha1.StateRecoveryStatus{
+			ErroredState: true,
+			Lock:         &tfreconcilev1alpha1.StateLock{ID: "1234", Who: "krec@pod", Operation: "OperationTypeApply", Created: "2026-10-19"},
+		}))
+}
+
+func TestRequireRecovery(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, tfreconcilev1alpha1.AddToScheme(scheme))
+
+	ws := &tfreconcilev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws", Namespace: "default"},
+	}
+	recorder := record.NewFakeRecorder(10)
+	r := &WorkspaceReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(ws).WithStatusSubresource(ws).Build(),
+		Recorder: recorder,
+		Tf:       runner.New(t.TempDir()),
+	}
+	ctx := context.Background()
+
+	recovery := &tfreconcilev1alpha1.StateRecoveryStatus{Lock: &tfreconcilev1alpha1.StateLock{ID: "1234"}}
+	require.NoError(t, r.requireRecovery(ctx, ws, recovery))
+	assert.True(t, meta.IsStatusConditionTrue(ws.Status.Conditions, tfreconcilev1alpha1.ConditionStateRecoveryRequired))
+	assert.Len(t, recorder.Events, 1)
+
+	// Recording the same recovery again neither updates the workspace nor emits another event
+	require.NoError(t, r.requireRecovery(ctx, ws, recovery.DeepCopy()))
+	assert.Len(t, recorder.Events, 1)
+
+	// A lock reported by terraform in an earlier run is remembered through the status
+	detected, err := r.detectRecovery(*ws)
+	require.NoError(t, err)
+	assert.Equal(t, recovery, detected)
+}
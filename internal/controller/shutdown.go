@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// RunTracker keeps track of the runs in flight so the operator can shut down without aborting them. On
+// shutdown it stops new runs from starting and gives the runs in flight GracePeriod to finish, after which
+// their contexts are cancelled, which makes terraform-exec interrupt terraform so it can release its locks.
+//
+// It is a manager.Runnable, the shutdown starts when the manager stops it.
+type RunTracker struct {
+	GracePeriod time.Duration
+
+	mu      sync.Mutex
+	closing bool
+	runs    map[*Run]struct{}
+	wg      sync.WaitGroup
+}
+
+func NewRunTracker(gracePeriod time.Duration) *RunTracker {
+	return &RunTracker{
+		GracePeriod: gracePeriod,
+		runs:        map[*Run]struct{}{},
+	}
+}
+
+// Run is a reconcile of a workspace in flight.
+type Run struct {
+	tracker     *RunTracker
+	cancel      context.CancelFunc
+	interrupted atomic.Bool
+
+	mu        sync.Mutex
+	operation string
+}
+
+// Begin registers a run. The returned context is detached from the cancellation of ctx and is only cancelled
+// when the run is interrupted by a shutdown. It returns false if the operator is shutting down, in which case
+// the run must not start. A nil tracker never refuses and never interrupts runs.
+func (t *RunTracker) Begin(ctx context.Context) (*Run, context.Context, bool) {
+	if t == nil {
+		return &Run{}, ctx, true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closing {
+		return nil, ctx, false
+	}
+
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	run := &Run{tracker: t, cancel: cancel}
+	t.runs[run] = struct{}{}
+	t.wg.Add(1)
+	return run, runCtx, true
+}
+
+// End marks the run as finished.
+func (r *Run) End() {
+	if r.tracker == nil {
+		return
+	}
+
+	r.tracker.mu.Lock()
+	delete(r.tracker.runs, r)
+	r.tracker.mu.Unlock()
+	r.cancel()
+	r.tracker.wg.Done()
+}
+
+// SetOperation records the terraform operation the run is carrying out.
+func (r *Run) SetOperation(operation string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.operation = operation
+}
+
+// Operation returns the terraform operation the run is carrying out.
+func (r *Run) Operation() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.operation
+}
+
+// Interrupted reports whether the run was interrupted by a shutdown.
+func (r *Run) Interrupted() bool {
+	return r.interrupted.Load()
+}
+
+// Start waits for ctx to be cancelled and then shuts down, see RunTracker.
+func (t *RunTracker) Start(ctx context.Context) error {
+	<-ctx.Done()
+	t.Shutdown()
+	return nil
+}
+
+// NeedLeaderElection is false, runs must be waited for whether or not this replica is the leader.
+func (t *RunTracker) NeedLeaderElection() bool {
+	return false
+}
+
+// Shutdown stops new runs from starting, waits up to GracePeriod for the runs in flight and interrupts the
+// ones still running after that. It returns once all runs have ended.
+func (t *RunTracker) Shutdown() {
+	t.mu.Lock()
+	t.closing = true
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-time.After(t.GracePeriod):
+	}
+
+	t.mu.Lock()
+	for run := range t.runs {
+		run.interrupted.Store(true)
+		run.cancel()
+	}
+	t.mu.Unlock()
+
+	<-done
+}
+
+// recordInterruptedRun records in the status of the workspace that its run was interrupted by a shutdown. The
+// context of the run is cancelled by then, so the status is written with a context of its own.
+func (r *WorkspaceReconciler) recordInterruptedRun(ctx context.Context, key types.NamespacedName, operation string) {
+	log := logf.FromContext(ctx)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	var ws tfreconcilev1alpha1.Workspace
+	err := r.Client.Get(ctx, key, &ws)
+	if apierrors.IsNotFound(err) {
+		return
+	}
+	if err != nil {
+		log.Error(err, "failed to get workspace to record interrupted run")
+		return
+	}
+
+	r.Recorder.Eventf(&ws, v1.EventTypeWarning, TFInterruptedEventReason, "Terraform %s interrupted by operator shutdown", operation)
+	ws.Status.InterruptedRun = &tfreconcilev1alpha1.InterruptedRun{
+		Operation:     operation,
+		Generation:    ws.Generation,
+		InterruptedAt: metav1.Now(),
+	}
+	err = r.Client.Status().Update(ctx, &ws)
+	if err != nil {
+		log.Error(err, "failed to record interrupted run")
+	}
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRunTrackerWaitsForRuns(t *testing.T) {
+	tracker := NewRunTracker(time.Minute)
+	reconcileCtx, cancel := context.WithCancel(context.Background())
+
+	run, ctx, ok := tracker.Begin(reconcileCtx)
+	require.True(t, ok)
+
+	// The manager cancelling the reconcile context does not cancel the run
+	cancel()
+	assert.NoError(t, ctx.Err())
+
+	shutdown := make(chan struct{})
+	go func() {
+		tracker.Shutdown()
+		close(shutdown)
+	}()
+
+	assert.Eventually(t, func() bool {
+		_, _, ok := tracker.Begin(context.Background())
+		return !ok
+	}, time.Second, 10*time.Millisecond, "no new runs start once shutting down")
+
+	select {
+	case <-shutdown:
+		t.Fatal("shutdown returned while a run was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	run.End()
+	<-shutdown
+	assert.False(t, run.Interrupted())
+}
+
+func TestRunTrackerInterruptsRunsAfterGracePeriod(t *testing.T) {
+	tracker := NewRunTracker(10 * time.Millisecond)
+
+	run, ctx, ok := tracker.Begin(context.Background())
+	require.True(t, ok)
+	run.SetOperation("apply")
+	go func() {
+		<-ctx.Done()
+		run.End()
+	}()
+
+	tracker.Shutdown()
+	assert.True(t, run.Interrupted())
+	assert.Equal(t, "apply", run.Operation())
+}
+
+func TestNilRunTracker(t *testing.T) {
+	var tracker *RunTracker
+	ctx := context.Background()
+
+	run, runCtx, ok := tracker.Begin(ctx)
+	require.True(t, ok)
+	assert.Equal(t, ctx, runCtx)
+	run.SetOperation("plan")
+	run.End()
+	assert.False(t, run.Interrupted())
+}
+
+func TestRecordInterruptedRun(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, tfreconcilev1alpha1.AddToScheme(scheme))
+
+	ws := &tfreconcilev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws", Namespace: "default", Generation: 3},
+	}
+	recorder := record.NewFakeRecorder(10)
+	r := &WorkspaceReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(ws).WithStatusSubresource(ws).Build(),
+		Recorder: recorder,
+	}
+
+	// The context of an interrupted run is cancelled already
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	key := types.NamespacedName{Namespace: "default", Name: "ws"}
+	r.recordInterruptedRun(ctx, key, "apply")
+
+	var got tfreconcilev1alpha1.Workspace
+	require.NoError(t, r.Client.Get(context.Background(), key, &got))
+	require.NotNil(t, got.Status.InterruptedRun)
+	assert.Equal(t, "apply", got.Status.InterruptedRun.Operation)
+	assert.Equal(t, int64(3), got.Status.InterruptedRun.Generation)
+	assert.Len(t, recorder.Events, 1)
+
+	// A workspace deleted by the interrupted run is ignored
+	r.recordInterruptedRun(ctx, types.NamespacedName{Namespace: "default", Name: "gone"}, "destroy")
+	assert.Len(t, recorder.Events, 1)
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// terraformRC returns the CLI configuration of the workspace: spec.terraformRC followed by the content of the
+// Secret key of spec.terraformRCFrom.
+func (r *WorkspaceReconciler) terraformRC(ctx context.Context, ws tfreconcilev1alpha1.Workspace) (string, error) {
+	if ws.Spec.TerraformRCFrom == nil {
+		return ws.Spec.TerraformRC, nil
+	}
+
+	content, err := r.secretValue(ctx, ws, *ws.Spec.TerraformRCFrom)
+	if err != nil {
+		return "", fmt.Errorf("failed to read terraformRCFrom: %w", err)
+	}
+	terraformRC := ws.Spec.TerraformRC
+	if terraformRC != "" && !strings.HasSuffix(terraformRC, "\n") {
+		terraformRC += "\n"
+	}
+	return terraformRC + content, nil
+}
+
+// registryCredentialEnvs returns the TF_TOKEN_<host> environment variables of the registry credentials of the
+// workspace.
+func (r *WorkspaceReconciler) registryCredentialEnvs(ctx context.Context, ws tfreconcilev1alpha1.Workspace) (map[string]string, error) {
+	envs := map[string]string{}
+	for _, credential := range ws.Spec.RegistryCredentials {
+		token, err := r.secretValue(ctx, ws, credential.SecretKeyRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read credentials of %s: %w", credential.Host, err)
+		}
+		envs[registryTokenEnv(credential.Host)] = strings.TrimSpace(token)
+	}
+
+	return envs, nil
+}
+
+// registryTokenEnv is the name of the environment variable terraform reads the token of host from: periods
+// are encoded as underscores and hyphens as double underscores.
+func registryTokenEnv(host string) string {
+	name := strings.ReplaceAll(host, "-", "__")
+	name = strings.ReplaceAll(name, ".", "_")
+	return "TF_TOKEN_" + name
+}
+
+// secretValue reads a key of a Secret in the namespace of the workspace with referenceReader.
+func (r *WorkspaceReconciler) secretValue(ctx context.Context, ws tfreconcilev1alpha1.Workspace, selector tfreconcilev1alpha1.SecretKeySelector) (string, error) {
+	reader, err := r.referenceReader(ws)
+	if err != nil {
+		return "", err
+	}
+
+	var secret v1.Secret
+	err = reader.Get(ctx, client.ObjectKey{Namespace: ws.Namespace, Name: selector.Name}, &secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s: %w", selector.Name, err)
+	}
+	value, ok := secret.Data[selector.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %s", selector.Name, selector.Key)
+	}
+
+	return string(value), nil
+}
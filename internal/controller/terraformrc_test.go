@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newRegistryTestReconciler(t *testing.T) *WorkspaceReconciler {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "default"},
+		Data: map[string][]byte{
+			"terraformrc": []byte("credentials \"app.terraform.io\" {\n  token = \"secret\"\n}\n"),
+			"hcp":         []byte("hcp-token\n"),
+			"private":     []byte("private-token"),
+		},
+	}
+	c := fakeclient.NewClientBuilder().WithScheme(newLifecycleScheme(t)).WithObjects(secret).Build()
+	return impersonateWith(&WorkspaceReconciler{Client: c}, c)
+}
+
+func TestRegistryTokenEnv(t *testing.T) {
+	assert.Equal(t, "TF_TOKEN_app_terraform_io", registryTokenEnv("app.terraform.io"))
+	assert.Equal(t, "TF_TOKEN_my__registry_example_com", registryTokenEnv("my-registry.example.com"))
+}
+
+func TestTerraformRC(t *testing.T) {
+	r := newRegistryTestReconciler(t)
+	ws := tfreconcilev1alpha1.Workspace{ObjectMeta: metav1.ObjectMeta{Name: "ws", Namespace: "default"}}
+	ws.Spec.TerraformRC = "plugin_cache_dir = \"/cache\""
+
+	terraformRC, err := r.terraformRC(context.Background(), ws)
+	require.NoError(t, err)
+	assert.Equal(t, "plugin_cache_dir = \"/cache\"", terraformRC)
+
+	ws.Spec.TerraformRCFrom = &tfreconcilev1alpha1.SecretKeySelector{Name: "registry", Key: "terraformrc"}
+	terraformRC, err = r.terraformRC(context.Background(), ws)
+	require.NoError(t, err)
+	assert.Equal(t, "plugin_cache_dir = \"/cache\"\ncredentials \"app.terraform.io\" {\n  token = \"secret\"\n}\n", terraformRC)
+
+	ws.Spec.TerraformRCFrom.Key = "missing"
+	_, err = r.terraformRC(context.Background(), ws)
+	assert.ErrorContains(t, err, "secret registry has no key missing")
+}
+
+func TestGetEnvsForExecutionRegistryCredentials(t *testing.T) {
+	r := newRegistryTestReconciler(t)
+	ws := tfreconcilev1alpha1.Workspace{ObjectMeta: metav1.ObjectMeta{Name: "ws", Namespace: "default"}}
+	ws.Spec.RegistryCredentials = []tfreconcilev1alpha1.RegistryCredential{
+		{Host: "app.terraform.io", SecretKeyRef: tfreconcilev1alpha1.SecretKeySelector{Name: "registry", Key: "hcp"}},
+		{Host: "my-registry.example.com", SecretKeyRef: tfreconcilev1alpha1.SecretKeySelector{Name: "registry", Key: "private"}},
+	}
+
+	envs, cleanup, err := r.getEnvsForExecution(context.Background(), ws)
+	require.NoError(t, err)
+	defer cleanup()
+	assert.Equal(t, map[string]string{
+		"TF_TOKEN_app_terraform_io":         "hcp-token",
+		"TF_TOKEN_my__registry_example_com": "private-token",
+	}, envs)
+
+	ws.Spec.RegistryCredentials[0].SecretKeyRef.Name = "missing"
+	_, _, err = r.getEnvsForExecution(context.Background(), ws)
+	assert.ErrorContains(t, err, "failed to read credentials of app.terraform.io")
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"lukaspj.io/kube-tf-reconciler/pkg/vault"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// defaultVaultAuthPath is where the Kubernetes auth method is mounted unless configured otherwise.
+const defaultVaultAuthPath = "kubernetes"
+
+// vaultRevokeTimeout bounds revoking the lease and token at the end of a run, which may have been interrupted.
+const vaultRevokeTimeout = 30 * time.Second
+
+// vaultLease is a secret read from Vault for a run. The lease of the secret and the token it was read with
+// are renewed in the background until it is closed, and closing it revokes both.
+type vaultLease struct {
+	ctx     context.Context
+	client  *vault.Client
+	token   string
+	leaseID string
+
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// startRenewal renews the token and the lease of the secret in the background, as long as Vault allows.
+func (l *vaultLease) startRenewal(auth *vault.Auth, secret *vault.Secret) {
+	ctx, stop := context.WithCancel(l.ctx)
+	l.stop = stop
+
+	if auth.Renewable && auth.LeaseDuration > 0 {
+		l.wg.Add(1)
+		go l.renew(ctx, "token", auth.LeaseDuration, func(ctx context.Context) (int, bool, error) {
+			renewed, err := l.client.RenewSelf(ctx, l.token)
+			if err != nil {
+				return 0, false, err
+			}
+			return renewed.LeaseDuration, renewed.Renewable, nil
+		})
+	}
+	if secret.Renewable && secret.LeaseID != "" && secret.LeaseDuration > 0 {
+		l.wg.Add(1)
+		go l.renew(ctx, "lease", secret.LeaseDuration, func(ctx context.Context) (int, bool, error) {
+			renewed, err := l.client.RenewLease(ctx, l.token, l.leaseID)
+			if err != nil {
+				return 0, false, err
+			}
+			return renewed.LeaseDuration, renewed.Renewable, nil
+		})
+	}
+}
+
+// renew calls renewFn before each lease of the given number of seconds expires, until ctx is done or the
+// lease can no longer be extended, which happens once it reaches its maximum lifetime.
+func (l *vaultLease) renew(ctx context.Context, what string, seconds int, renewFn func(ctx context.Context) (int, bool, error)) {
+	defer l.wg.Done()
+	log := logf.FromContext(ctx)
+
+	wait := refreshAfter(time.Now().Add(time.Duration(seconds) * time.Second))
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		seconds, renewable, err := renewFn(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error(err, "failed to renew vault "+what)
+			}
+			wait = tokenRetryInterval
+			continue
+		}
+		if !renewable || seconds <= 0 {
+			return
+		}
+		wait = refreshAfter(time.Now().Add(time.Duration(seconds) * time.Second))
+	}
+}
+
+// Close stops the renewal and revokes the lease and the token.
+func (l *vaultLease) Close() error {
+	if l.stop != nil {
+		l.stop()
+		l.wg.Wait()
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(l.ctx), vaultRevokeTimeout)
+	defer cancel()
+
+	var errs []error
+	if l.leaseID != "" {
+		errs = append(errs, l.client.RevokeLease(ctx, l.token, l.leaseID))
+	}
+	errs = append(errs, l.client.RevokeSelf(ctx, l.token))
+	return errors.Join(errs...)
+}
+
+// setupVaultAuthentication logs in to Vault with a token of the configured service account, reads the
+// configured secret and maps its fields to environment variables.
+func (r *WorkspaceReconciler) setupVaultAuthentication(ctx context.Context, ws tfreconcilev1alpha1.Workspace) (map[string]string, *vaultLease, error) {
+	config := ws.Spec.Authentication.Vault
+	authPath := config.AuthPath
+	if authPath == "" {
+		authPath = defaultVaultAuthPath
+	}
+	var audiences []string
+	if config.Audience != "" {
+		audiences = []string{config.Audience}
+	}
+
+	tokenRequest, err := r.requestToken(ctx, ws, config.ServiceAccountName, audiences, tokenExpiration)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client := &vault.Client{Address: config.Address, Namespace: config.Namespace}
+	auth, err := client.LoginKubernetes(ctx, authPath, config.Role, tokenRequest.Status.Token)
+	if err != nil {
+		return nil, nil, err
+	}
+	lease := &vaultLease{ctx: ctx, client: client, token: auth.ClientToken}
+
+	secret, err := client.Read(ctx, lease.token, config.SecretPath)
+	if err != nil {
+		return nil, nil, errors.Join(err, lease.Close())
+	}
+	lease.leaseID = secret.LeaseID
+
+	envs := map[string]string{}
+	for _, env := range config.Env {
+		value, ok := secret.Data[env.Field]
+		if !ok {
+			return nil, nil, errors.Join(fmt.Errorf("secret %s has no field %s", config.SecretPath, env.Field), lease.Close())
+		}
+		envs[env.Name], err = vaultEnvValue(value)
+		if err != nil {
+			return nil, nil, errors.Join(fmt.Errorf("failed to convert field %s of secret %s: %w", env.Field, config.SecretPath, err), lease.Close())
+		}
+	}
+	lease.startRenewal(auth, secret)
+
+	return envs, lease, nil
+}
+
+// vaultEnvValue returns strings as they are and anything else as JSON.
+func vaultEnvValue(value any) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	content, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
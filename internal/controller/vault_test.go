@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+// fakeVault stands in for a Vault server with the Kubernetes auth method and an AWS secrets engine.
+type fakeVault struct {
+	// ttl is the number of seconds tokens and leases are valid for, which makes them renewable if set.
+	ttl int
+
+	mu      sync.Mutex
+	renewed []string
+	revoked []string
+}
+
+func (v *fakeVault) start(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/auth/kubernetes/login", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if body["jwt"] != "sa-token" || body["role"] != "terraform" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, `{"auth": {"client_token": "vault-token", "lease_duration": %d, "renewable": %t}}`, v.ttl, v.ttl > 0)
+	})
+	mux.HandleFunc("POST /v1/auth/token/renew-self", func(w http.ResponseWriter, r *http.Request) {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		v.renewed = append(v.renewed, r.Header.Get("X-Vault-Token"))
+		fmt.Fprintf(w, `{"auth": {"client_token": "vault-token", "lease_duration": %d, "renewable": true}}`, v.ttl)
+	})
+	mux.HandleFunc("PUT /v1/sys/leases/renew", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		v.renewed = append(v.renewed, body["lease_id"])
+		fmt.Fprintf(w, `{"lease_id": %q, "lease_duration": %d, "renewable": true}`, body["lease_id"], v.ttl)
+	})
+	mux.HandleFunc("GET /v1/aws/creds/terraform", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, `{"lease_id": "aws/creds/terraform/1", "lease_duration": %d, "renewable": %t, "data": {"access_key": "AKIA", "secret_key": "secret", "security_token": null}}`, cmp.Or(v.ttl, 3600), v.ttl > 0)
+	})
+	mux.HandleFunc("PUT /v1/sys/leases/revoke", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		v.revoked = append(v.revoked, body["lease_id"])
+	})
+	mux.HandleFunc("POST /v1/auth/token/revoke-self", func(w http.ResponseWriter, r *http.Request) {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		v.revoked = append(v.revoked, r.Header.Get("X-Vault-Token"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (v *fakeVault) Renewed() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return slices.Clone(v.renewed)
+}
+
+func (v *fakeVault) Revoked() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return slices.Clone(v.revoked)
+}
+
+func newVaultWorkspace(address string) tfreconcilev1alpha1.Workspace {
+	return tfreconcilev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws", Namespace: "default"},
+		Spec: tfreconcilev1alpha1.WorkspaceSpec{
+			Authentication: &tfreconcilev1alpha1.AuthenticationSpec{
+				Vault: &tfreconcilev1alpha1.VaultAuthConfig{
+					Address:            address,
+					ServiceAccountName: "terraform",
+					Role:               "terraform",
+					SecretPath:         "aws/creds/terraform",
+					Env: []tfreconcilev1alpha1.VaultEnvVar{
+						{Name: "AWS_ACCESS_KEY_ID", Field: "access_key"},
+						{Name: "AWS_SECRET_ACCESS_KEY", Field: "secret_key"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func newVaultTestReconciler(t *testing.T) *WorkspaceReconciler {
+	return &WorkspaceReconciler{Client: newTokenClient(t, func(tr *authv1.TokenRequest) {
+		tr.Status.Token = "sa-token"
+		tr.Status.ExpirationTimestamp = metav1.NewTime(time.Now().Add(time.Hour))
+	})}
+}
+
+func TestGetEnvsForExecutionVault(t *testing.T) {
+	vault := &fakeVault{}
+	srv := vault.start(t)
+	r := newVaultTestReconciler(t)
+
+	envs, cleanup, err := r.getEnvsForExecution(context.Background(), newVaultWorkspace(srv.URL))
+	require.NoError(t, err)
+	assert.Equal(t, "AKIA", envs["AWS_ACCESS_KEY_ID"])
+	assert.Equal(t, "secret", envs["AWS_SECRET_ACCESS_KEY"])
+	assert.Empty(t, vault.Revoked())
+
+	cleanup()
+	assert.Equal(t, []string{"aws/creds/terraform/1", "vault-token"}, vault.Revoked())
+}
+
+func TestGetEnvsForExecutionVaultRenews(t *testing.T) {
+	vault := &fakeVault{ttl: 1}
+	srv := vault.start(t)
+	r := newVaultTestReconciler(t)
+
+	_, cleanup, err := r.getEnvsForExecution(context.Background(), newVaultWorkspace(srv.URL))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		renewed := vault.Renewed()
+		return slices.Contains(renewed, "vault-token") && slices.Contains(renewed, "aws/creds/terraform/1")
+	}, 5*time.Second, 50*time.Millisecond, "the token and the lease are renewed before they expire")
+
+	cleanup()
+	renewed := len(vault.Renewed())
+	assert.Equal(t, []string{"aws/creds/terraform/1", "vault-token"}, vault.Revoked())
+	time.Sleep(1500 * time.Millisecond)
+	assert.Len(t, vault.Renewed(), renewed, "renewal stops once the run is over")
+}
+
+func TestGetEnvsForExecutionVaultMissingField(t *testing.T) {
+	vault := &fakeVault{}
+	srv := vault.start(t)
+	r := newVaultTestReconciler(t)
+	ws := newVaultWorkspace(srv.URL)
+	ws.Spec.Authentication.Vault.Env = append(ws.Spec.Authentication.Vault.Env, tfreconcilev1alpha1.VaultEnvVar{Name: "X", Field: "missing"})
+
+	_, _, err := r.getEnvsForExecution(context.Background(), ws)
+	assert.ErrorContains(t, err, "has no field missing")
+	assert.Equal(t, []string{"aws/creds/terraform/1", "vault-token"}, vault.Revoked(), "the secret is revoked right away")
+}
+
+func TestGetEnvsForExecutionVaultLoginDenied(t *testing.T) {
+	vault := &fakeVault{}
+	srv := vault.start(t)
+	r := newVaultTestReconciler(t)
+	ws := newVaultWorkspace(srv.URL)
+	ws.Spec.Authentication.Vault.Role = "admin"
+
+	_, _, err := r.getEnvsForExecution(context.Background(), ws)
+	assert.ErrorContains(t, err, "failed to log in to vault")
+}
+
+func TestVaultEnvsAreNotHashed(t *testing.T) {
+	ws := newVaultWorkspace("http://vault")
+	v := version.Must(version.NewVersion("1.11.2"))
+
+	first := workspaceInputs(ws, v, nil, "", map[string]string{"AWS_ACCESS_KEY_ID": "AKIA1", "OTHER": "a"})
+	second := workspaceInputs(ws, v, nil, "", map[string]string{"AWS_ACCESS_KEY_ID": "AKIA2", "OTHER": "a"})
+	assert.Equal(t, first.Hash, second.Hash)
+
+	third := workspaceInputs(ws, v, nil, "", map[string]string{"AWS_ACCESS_KEY_ID": "AKIA2", "OTHER": "b"})
+	assert.NotEqual(t, first.Hash, third.Hash)
+}
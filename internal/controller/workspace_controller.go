@@ -2,21 +2,25 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
-	"path/filepath"
+	"slices"
 	"time"
 
-	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/hashicorp/terraform-exec/tfexec"
-	authv1 "k8s.io/api/authentication/v1"
 	v1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
 	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"lukaspj.io/kube-tf-reconciler/pkg/inspect"
 	"lukaspj.io/kube-tf-reconciler/pkg/render"
 	"lukaspj.io/kube-tf-reconciler/pkg/runner"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -27,10 +31,15 @@ import (
 )
 
 const (
-	TFErrEventReason     = "TerraformError"
-	TFPlanEventReason    = "TerraformPlan"
-	TFApplyEventReason   = "TerraformApply"
-	TFDestroyEventReason = "TerraformDestroy"
+	TFErrEventReason         = "TerraformError"
+	TFPlanEventReason        = "TerraformPlan"
+	TFApplyEventReason       = "TerraformApply"
+	TFDestroyEventReason     = "TerraformDestroy"
+	TFInputEventReason       = "TerraformModuleInput"
+	TFValidateEventReason    = "TerraformValidate"
+	TFRecoveryEventReason    = "TerraformStateRecovery"
+	TFInterruptedEventReason = "TerraformInterrupted"
+	TFCancelEventReason      = "TerraformCancel"
 
 	// Finalizer name
 	workspaceFinalizer = "tf-reconcile.lukaspj.io/finalizer"
@@ -43,11 +52,32 @@ type WorkspaceReconciler struct {
 	Recorder record.EventRecorder
 
 	Tf *runner.Exec
+	// RefreshInterval is how often workspaces are planned again even if their inputs are unchanged, to
+	// pick up drift. Zero disables periodic refreshes.
+	RefreshInterval time.Duration
+	// Runs tracks the runs in flight so they can finish when the operator shuts down. Nil runs are never
+	// waited for.
+	Runs *RunTracker
+	// RestConfig is the config the clients impersonating the service accounts of workspaces are created
+	// from. Workspaces that reference Secrets or ConfigMaps fail without it.
+	RestConfig *rest.Config
+
+	impersonated impersonatedClients
 }
 
 func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := logf.FromContext(ctx)
 	reqStart := time.Now()
+
+	// The run gets a context of its own so a shutdown lets it finish instead of interrupting it right away
+	run, ctx, ok := r.Runs.Begin(ctx)
+	if !ok {
+		log.Info("operator is shutting down, not starting a new run")
+		return ctrl.Result{Requeue: true}, nil
+	}
+	defer run.End()
+	run.SetOperation("setup")
+
 	var ws tfreconcilev1alpha1.Workspace
 	if err := r.Client.Get(ctx, req.NamespacedName, &ws); err != nil {
 		if !apierrors.IsNotFound(err) {
@@ -56,45 +86,125 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 		return ctrl.Result{}, nil
 	}
+	defer func() {
+		if run.Interrupted() {
+			r.recordInterruptedRun(ctx, req.NamespacedName, run.Operation())
+		}
+	}()
 
-	if r.dueForRefresh(reqStart, ws) {
+	unlock, err := r.Tf.LockWorkspace(ctx, ws)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	defer unlock()
 
+	refreshDue := r.dueForRefresh(reqStart, ws)
+	upgrade := upgradeRequested(ws)
+	// Runs interrupted before a restart may have left the state locked or errored, running again on top of
+	// that only fails again until someone decides how to recover
+	recovery, err := r.detectRecovery(ws)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to detect state recovery of %s: %w", req.String(), err)
+	}
+	if recovery != nil && !recoveryRequested(ws) {
+		log.Info("workspace state requires recovery, skipping")
+		return ctrl.Result{}, r.requireRecovery(ctx, &ws, recovery)
+	}
+	if recovery == nil && recoveryRequested(ws) {
+		r.Recorder.Eventf(&ws, v1.EventTypeNormal, TFRecoveryEventReason, "Nothing to recover, ignoring recovery annotations")
+		return ctrl.Result{}, r.clearRecoveryRequest(ctx, &ws)
+	}
+	// A run in progress removes the cancel annotation itself once it has stopped
+	if cancelRequested(ws) {
+		r.Recorder.Eventf(&ws, v1.EventTypeNormal, TFCancelEventReason, "No run in progress, ignoring cancel annotation")
+		return ctrl.Result{}, r.clearCancelRequest(ctx, &ws)
 	}
 
-	if r.alreadyProcessedOnce(ws) {
+	interrupted := ws.Status.InterruptedRun != nil
+	if ws.DeletionTimestamp.IsZero() && r.alreadyProcessedOnce(ws) && !refreshDue && !upgrade && recovery == nil && !interrupted {
 		log.Info("already processed workspace, skipping")
-		return ctrl.Result{}, nil
+		return r.requeueForRefresh(reqStart, ws), nil
 	}
 
-	envs, err := r.getEnvsForExecution(ctx, ws)
+	tfVersion, err := r.Tf.ResolveVersion(ctx, ws)
+	if err != nil {
+		err = fmt.Errorf("failed to resolve terraform version %s: %w", req.String(), err)
+		r.Recorder.Eventf(&ws, v1.EventTypeWarning, TFErrEventReason, err.Error())
+		if !errors.Is(err, runner.ErrInvalidVersion) {
+			return ctrl.Result{}, err
+		}
+
+		// Retrying won't help until the spec changes
+		meta.SetStatusCondition(&ws.Status.Conditions, metav1.Condition{
+			Type:               tfreconcilev1alpha1.ConditionVersionResolved,
+			Status:             metav1.ConditionFalse,
+			Reason:             "InvalidVersion",
+			Message:            err.Error(),
+			ObservedGeneration: ws.Generation,
+		})
+		ws.Status.ResolvedVersion = ""
+		ws.Status.ObservedGeneration = ws.Generation
+		return ctrl.Result{}, r.Client.Status().Update(ctx, &ws)
+	}
+	meta.SetStatusCondition(&ws.Status.Conditions, metav1.Condition{
+		Type:               tfreconcilev1alpha1.ConditionVersionResolved,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Resolved",
+		Message:            fmt.Sprintf("%q resolved to %s", ws.Spec.TerraformVersion, tfVersion),
+		ObservedGeneration: ws.Generation,
+	})
+	ws.Status.ResolvedVersion = tfVersion.String()
+
+	envs, cleanupEnvs, err := r.getEnvsForExecution(ctx, ws)
 	if err != nil {
 		err = fmt.Errorf("failed to get envs for execution: %w", err)
 		r.Recorder.Eventf(&ws, v1.EventTypeWarning, TFErrEventReason, err.Error())
 		return ctrl.Result{}, err
 	}
+	defer cleanupEnvs()
+	// The inputs hash covers the workspace env only, not what the operator adds below
+	runEnvs := maps.Clone(envs)
 
-	// Clean up temporary token file at the end of reconciliation
-	if tempTokenPath, exists := envs["AWS_WEB_IDENTITY_TOKEN_FILE"]; exists {
-		defer func() {
-			if err := os.Remove(tempTokenPath); err != nil {
-				log.Error(err, "failed to cleanup temp token file", "file", tempTokenPath)
-			}
-		}()
+	terraformRC, err := r.terraformRC(ctx, ws)
+	if err != nil {
+		r.Recorder.Eventf(&ws, v1.EventTypeWarning, TFErrEventReason, err.Error())
+		return ctrl.Result{}, err
 	}
 
-	tf, terraformRCPath, err := r.Tf.GetTerraformForWorkspace(ctx, ws)
+	tf, terraformRCPath, err := r.Tf.GetTerraformForWorkspace(ctx, ws, tfVersion, terraformRC)
 	if err != nil {
 		err = fmt.Errorf("failed to get terraform executable %s: %w", req.String(), err)
 		r.Recorder.Eventf(&ws, v1.EventTypeWarning, TFErrEventReason, err.Error())
 		return ctrl.Result{}, err
 	}
 
+	// Only the artifacts worth keeping make it from the run directory back into the workspace directory, and
+	// only if terraform ran. The workspace directory, including any errored.tfstate, is then persisted whatever
+	// the outcome of the run, even if it was interrupted.
+	keep := false
+	defer func() {
+		err := r.Tf.EndRun(ws, tf.WorkingDir(), keep)
+		if err != nil {
+			log.Error(err, "failed to end run")
+			return
+		}
+		if !keep {
+			return
+		}
+		if err := r.Tf.SaveWorkspace(context.WithoutCancel(ctx), ws); err != nil {
+			log.Error(err, "failed to save workspace")
+		}
+	}()
+
 	envs["HOME"] = os.Getenv("HOME")
 	envs["PATH"] = os.Getenv("PATH")
 
 	if terraformRCPath != "" {
 		envs["TF_CLI_CONFIG_FILE"] = terraformRCPath
 	}
+	for k, v := range r.Tf.PluginCache.Env() {
+		envs[k] = v
+	}
 
 	err = tf.SetEnv(envs)
 	if err != nil {
@@ -103,27 +213,119 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, err
 	}
 
-	result, err := r.renderHcl(tf.WorkingDir(), ws)
+	result, err := r.renderWorkspace(tf.WorkingDir(), ws)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to render workspace %s: %w", req.String(), err)
 	}
 
 	ws.Status.CurrentRender = string(result)
+
+	inputs := workspaceInputs(ws, tfVersion, result, terraformRC, runEnvs)
+	if ws.DeletionTimestamp.IsZero() && ws.Status.Inputs != nil && ws.Status.Inputs.Hash == inputs.Hash && !refreshDue && !upgrade && recovery == nil && !interrupted {
+		log.Info("inputs unchanged and no refresh due, skipping init and plan", "hash", inputs.Hash)
+		meta.SetStatusCondition(&ws.Status.Conditions, metav1.Condition{
+			Type:               tfreconcilev1alpha1.ConditionPlanSkipped,
+			Status:             metav1.ConditionTrue,
+			Reason:             "InputsUnchanged",
+			Message:            fmt.Sprintf("inputs hash %s is unchanged and no refresh is due", inputs.Hash),
+			ObservedGeneration: ws.Generation,
+		})
+		ws.Status.ObservedGeneration = ws.Generation
+		return r.requeueForRefresh(reqStart, ws), r.Client.Status().Update(ctx, &ws)
+	}
+	// Only a change of the inputs can fix an invalid render, running init again for a requested upgrade or a
+	// refresh would only find it invalid again
+	if ws.DeletionTimestamp.IsZero() && ws.Status.Inputs != nil && ws.Status.Inputs.Hash == inputs.Hash && !ws.Status.ValidRender && recovery == nil && !interrupted {
+		log.Info("inputs unchanged since the render was found invalid, skipping init and plan", "hash", inputs.Hash)
+		return ctrl.Result{}, nil
+	}
+	keep = true
+
+	tfCtx, stopWatching := r.watchCancel(ctx, req.NamespacedName)
+	defer stopWatching()
+	timeouts := timeouts(ws)
+
+	reason, message := runReason(ws.Status.Inputs, inputs)
+	meta.SetStatusCondition(&ws.Status.Conditions, metav1.Condition{
+		Type:               tfreconcilev1alpha1.ConditionPlanSkipped,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: ws.Generation,
+	})
+
 	err = r.Client.Status().Update(ctx, &ws)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to update workspace status %s: %w", req.String(), err)
 	}
 
-	err = tf.Init(ctx, tfexec.Upgrade(true))
+	run.SetOperation("init")
+	lockFile, err := r.restoreLockFile(ctx, ws, tf.WorkingDir())
+	if err != nil {
+		r.Recorder.Eventf(&ws, v1.EventTypeWarning, TFErrEventReason, err.Error())
+		return ctrl.Result{}, err
+	}
+
+	// Providers are only upgraded on request, otherwise the versions recorded in the lock file are kept
+	if upgrade {
+		err = r.Tf.ForgetInit(tf)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	initCtx, cancel := operationContext(tfCtx, timeouts.Init)
+	_, err = r.Tf.InitIfChanged(initCtx, tf, inputs.Hash, upgrade)
+	cancel()
 	if err != nil {
+		if handled, handledErr := r.operationFailed(ctx, &ws, tf.WorkingDir(), initCtx, "init", err); handled {
+			return ctrl.Result{}, handledErr
+		}
 		return ctrl.Result{}, fmt.Errorf("failed to init workspace: %w", err)
 	}
 
-	valResult, err := tf.Validate(ctx)
+	newLockFile, err := r.persistLockFile(ctx, &ws, tf.WorkingDir(), lockFile)
 	if err != nil {
+		r.Recorder.Eventf(&ws, v1.EventTypeWarning, TFErrEventReason, err.Error())
+		return ctrl.Result{}, err
+	}
+	var providerChanges []string
+	if upgrade {
+		providerChanges, err = lockFileProviderChanges(lockFile, newLockFile)
+		if err != nil {
+			log.Error(err, "failed to compare lock files")
+		}
+	}
+
+	if recovery != nil {
+		recovery, err = r.recoverState(ctx, &ws, tf, recovery)
+		if err != nil {
+			err = fmt.Errorf("failed to recover state of %s: %w", req.String(), err)
+			r.Recorder.Eventf(&ws, v1.EventTypeWarning, TFErrEventReason, err.Error())
+			return ctrl.Result{}, err
+		}
+		if recovery != nil {
+			return ctrl.Result{}, r.requireRecovery(ctx, &ws, recovery)
+		}
+	}
+
+	err = r.inspectModule(&ws, tf.WorkingDir())
+	if err != nil {
+		log.Error(err, "failed to inspect module variables")
+	}
+
+	run.SetOperation("validate")
+	valResult, err := tf.Validate(tfCtx)
+	if err != nil {
+		if handled, handledErr := r.operationFailed(ctx, &ws, tf.WorkingDir(), tfCtx, "validate", err); handled {
+			return ctrl.Result{}, handledErr
+		}
 		return ctrl.Result{}, fmt.Errorf("failed to validate workspace: %w", err)
 	}
 	ws.Status.ValidRender = valResult.Valid
+	ws.Status.ValidationDiagnostics = convertDiagnostics(valResult.Diagnostics)
+	for _, d := range ws.Status.ValidationDiagnostics {
+		r.Recorder.Eventf(&ws, v1.EventTypeWarning, TFValidateEventReason, "%s", diagnosticMessage(d))
+	}
 	err = r.Client.Status().Update(ctx, &ws)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to update workspace status %s: %w", req.String(), err)
@@ -131,19 +333,32 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 	if !ws.DeletionTimestamp.IsZero() {
 		if controllerutil.ContainsFinalizer(&ws, workspaceFinalizer) {
-			err = tf.Destroy(ctx)
+			run.SetOperation("destroy")
+			destroyCtx, cancel := operationContext(tfCtx, timeouts.Destroy)
+			err = tf.Destroy(destroyCtx)
+			cancel()
 			if err != nil {
+				if handled, handledErr := r.operationFailed(ctx, &ws, tf.WorkingDir(), destroyCtx, "destroy", err); handled {
+					return ctrl.Result{}, handledErr
+				}
 				return ctrl.Result{}, fmt.Errorf("failed to destroy resource: %w", err)
 			}
 
 			r.Recorder.Eventf(&ws, v1.EventTypeNormal, TFDestroyEventReason, "Successfully destroyed resources")
 
+			err = r.Tf.DeleteWorkspace(ctx, ws)
+			if err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to delete workspace directory: %w", err)
+			}
+			keep = false
+
 			controllerutil.RemoveFinalizer(&ws, workspaceFinalizer)
 			if err := r.Update(ctx, &ws); err != nil {
 				return ctrl.Result{}, err
 			}
+			// Without the finalizer the workspace is usually gone by now
 			ws.Status.ObservedGeneration = ws.Generation
-			return ctrl.Result{}, r.Client.Status().Update(ctx, &ws)
+			return ctrl.Result{}, client.IgnoreNotFound(r.Client.Status().Update(ctx, &ws))
 
 		}
 		// Stop reconciliation as resource is being deleted
@@ -158,8 +373,37 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{Requeue: true}, nil
 	}
 
-	changed, err := tf.Plan(ctx, tfexec.Out("plan.out"))
+	// The plan of an earlier render no longer describes the spec, and a requested provider upgrade stays
+	// requested until a plan reports what it changed
+	if !ws.Status.ValidRender {
+		log.Info("workspace render is invalid, skipping plan and apply")
+		meta.SetStatusCondition(&ws.Status.Conditions, metav1.Condition{
+			Type:               tfreconcilev1alpha1.ConditionPlanSkipped,
+			Status:             metav1.ConditionTrue,
+			Reason:             "InvalidRender",
+			Message:            fmt.Sprintf("terraform validate reported %d diagnostics", len(ws.Status.ValidationDiagnostics)),
+			ObservedGeneration: ws.Generation,
+		})
+		ws.Status.LatestPlan = ""
+		ws.Status.PlanSummary = nil
+		ws.Status.Inputs = &inputs
+		ws.Status.InterruptedRun = nil
+		ws.Status.ObservedGeneration = ws.Generation
+		err = r.Client.Status().Update(ctx, &ws)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to update workspace status %s: %w", req.String(), err)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	run.SetOperation("plan")
+	planCtx, cancel := operationContext(tfCtx, timeouts.Plan)
+	changed, err := tf.Plan(planCtx, "plan.out")
+	cancel()
 	if err != nil {
+		if handled, handledErr := r.operationFailed(ctx, &ws, tf.WorkingDir(), planCtx, "plan", err); handled {
+			return ctrl.Result{}, handledErr
+		}
 		err = fmt.Errorf("failed to plan workspace %s: %w", req.String(), err)
 		r.Recorder.Eventf(&ws, v1.EventTypeWarning, TFErrEventReason, err.Error())
 		return ctrl.Result{}, err
@@ -168,8 +412,15 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to show plan file: %w", err)
 	}
-	r.Recorder.Eventf(&ws, v1.EventTypeNormal, TFPlanEventReason, "Workspace %s planned", req.String())
+	planJSON, err := tf.ShowPlanFile(ctx, "plan.out")
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to show plan file as json: %w", err)
+	}
+	summary := summarizePlan(planJSON)
+	summary.ProviderChanges = providerChanges
+	r.Recorder.Eventf(&ws, v1.EventTypeNormal, TFPlanEventReason, "Workspace %s planned: %s", req.String(), planSummaryMessage(summary))
 	ws.Status.LatestPlan = plan
+	ws.Status.PlanSummary = &summary
 	err = r.Client.Status().Update(ctx, &ws)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to update workspace status %s: %w", req.String(), err)
@@ -178,47 +429,115 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	log.WithValues("changed", changed).Info("planned workspace")
 
 	if ws.Spec.AutoApply && changed {
-		err = tf.Apply(ctx)
+		run.SetOperation("apply")
+		applyCtx, cancel := operationContext(tfCtx, timeouts.Apply)
+		err = tf.Apply(applyCtx)
+		cancel()
 		if err != nil {
+			if handled, handledErr := r.operationFailed(ctx, &ws, tf.WorkingDir(), applyCtx, "apply", err); handled {
+				return ctrl.Result{}, handledErr
+			}
 			err = fmt.Errorf("failed to apply workspace %s: %w", req.String(), err)
 			r.Recorder.Eventf(&ws, v1.EventTypeWarning, TFErrEventReason, err.Error())
 			return ctrl.Result{}, err
 		}
 		r.Recorder.Eventf(&ws, v1.EventTypeNormal, TFApplyEventReason, "Workspace %s applied", req.String())
+
+		outputs, err := tf.Output(ctx)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to read outputs of workspace %s: %w", req.String(), err)
+		}
+		ws.Status.Outputs = outputStatuses(outputs)
 	}
 
+	r.scheduleRefresh(&ws)
+	meta.RemoveStatusCondition(&ws.Status.Conditions, tfreconcilev1alpha1.ConditionCancelled)
+	ws.Status.Inputs = &inputs
+	ws.Status.InterruptedRun = nil
 	ws.Status.ObservedGeneration = ws.Generation
-	return ctrl.Result{}, r.Client.Status().Update(ctx, &ws)
+	err = r.Client.Status().Update(ctx, &ws)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to update workspace status %s: %w", req.String(), err)
+	}
+	return r.requeueForRefresh(reqStart, ws), r.clearUpgradeRequest(ctx, &ws)
 }
 
-func (r *WorkspaceReconciler) renderHcl(workspaceDir string, ws tfreconcilev1alpha1.Workspace) ([]byte, error) {
-	f := hclwrite.NewEmptyFile()
-	err := render.Workspace(f.Body(), ws)
+func (r *WorkspaceReconciler) renderWorkspace(workspaceDir string, ws tfreconcilev1alpha1.Workspace) ([]byte, error) {
 	renderErr := fmt.Errorf("failed to render workspace %s/%s", ws.Namespace, ws.Name)
+	files, err := render.Files(ws, render.Format(ws.Spec.RenderFormat))
 	if err != nil {
-		return f.Bytes(), fmt.Errorf("%w: %w", renderErr, err)
+		return nil, fmt.Errorf("%w: %w", renderErr, err)
 	}
 
-	err = render.Providers(f.Body(), ws.Spec.ProviderSpecs)
+	err = render.Write(workspaceDir, files)
 	if err != nil {
-		return f.Bytes(), fmt.Errorf("%w: failed to render providers: %w", renderErr, err)
+		return nil, fmt.Errorf("%w: failed to write workspace: %w", renderErr, err)
 	}
 
-	err = render.Module(f.Body(), ws.Spec.Module)
+	return render.Concat(files), nil
+}
+
+// inspectModule records the variables of the installed module in the status, checks the module inputs
+// against them and emits an event for each problem found.
+func (r *WorkspaceReconciler) inspectModule(ws *tfreconcilev1alpha1.Workspace, workingDir string) error {
+	ws.Status.ModuleVariables = nil
+	ws.Status.InputProblems = nil
+
+	dir, err := inspect.ModuleDir(workingDir, ws.Spec.Module.Name)
 	if err != nil {
-		return f.Bytes(), fmt.Errorf("%w: failed to render module: %w", renderErr, err)
+		return err
+	}
+	vars, err := inspect.Variables(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range vars {
+		variable := tfreconcilev1alpha1.ModuleVariable{
+			Name:     v.Name,
+			Type:     v.TypeString(),
+			Required: v.Required,
+		}
+		def, err := v.DefaultJSON()
+		if err != nil {
+			return fmt.Errorf("failed to encode default of variable %s: %w", v.Name, err)
+		}
+		if def != nil {
+			variable.Default = &apiextensionsv1.JSON{Raw: def}
+		}
+		ws.Status.ModuleVariables = append(ws.Status.ModuleVariables, variable)
 	}
 
-	err = os.WriteFile(filepath.Join(workspaceDir, "main.tf"), f.Bytes(), 0644)
+	var rawInputs []byte
+	if ws.Spec.Module.Inputs != nil {
+		rawInputs = ws.Spec.Module.Inputs.Raw
+	}
+	problems, err := inspect.ValidateInputs(vars, rawInputs)
 	if err != nil {
-		return f.Bytes(), fmt.Errorf("%w: failed to write workspace: %w", renderErr, err)
+		return err
+	}
+
+	for _, p := range problems {
+		ws.Status.InputProblems = append(ws.Status.InputProblems, tfreconcilev1alpha1.InputProblem{
+			Reason:  string(p.Reason),
+			Input:   p.Input,
+			Message: p.Message,
+		})
+		r.Recorder.Eventf(ws, v1.EventTypeWarning, TFInputEventReason, "%s: %s", p.Reason, p.Message)
 	}
 
-	return f.Bytes(), nil
+	return nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *WorkspaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Runs != nil {
+		err := mgr.Add(r.Runs)
+		if err != nil {
+			return fmt.Errorf("failed to add run tracker: %w", err)
+		}
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&tfreconcilev1alpha1.Workspace{}).
 		WithOptions(controller.Options{MaxConcurrentReconciles: 10}).
@@ -230,26 +549,133 @@ func (r *WorkspaceReconciler) alreadyProcessedOnce(ws tfreconcilev1alpha1.Worksp
 }
 
 func (r *WorkspaceReconciler) dueForRefresh(t time.Time, ws tfreconcilev1alpha1.Workspace) bool {
-	return t.After(ws.Status.NextRefreshTimestamp.Time)
+	return !ws.Status.NextRefreshTimestamp.IsZero() && t.After(ws.Status.NextRefreshTimestamp.Time)
+}
+
+// scheduleRefresh sets when the workspace is next refreshed, if refreshes are enabled.
+func (r *WorkspaceReconciler) scheduleRefresh(ws *tfreconcilev1alpha1.Workspace) {
+	ws.Status.NextRefreshTimestamp = metav1.Time{}
+	if r.RefreshInterval > 0 {
+		ws.Status.NextRefreshTimestamp = metav1.NewTime(time.Now().Add(r.RefreshInterval))
+	}
+}
+
+// requeueForRefresh requeues the workspace for when its next refresh is due, if one is scheduled.
+func (r *WorkspaceReconciler) requeueForRefresh(t time.Time, ws tfreconcilev1alpha1.Workspace) ctrl.Result {
+	if ws.Status.NextRefreshTimestamp.IsZero() {
+		return ctrl.Result{}
+	}
+	return ctrl.Result{RequeueAfter: max(ws.Status.NextRefreshTimestamp.Sub(t), time.Second)}
 }
 
 func (r *WorkspaceReconciler) refreshState(ctx context.Context, ws tfreconcilev1alpha1.Workspace) error {
-	_, _, err := r.Tf.GetTerraformForWorkspace(ctx, ws)
+	tfVersion, err := r.Tf.ResolveVersion(ctx, ws)
+	if err != nil {
+		return fmt.Errorf("refreshState: failed to resolve terraform version %s: %w", ws.Name, err)
+	}
+	unlock, err := r.Tf.LockWorkspace(ctx, ws)
+	if err != nil {
+		return fmt.Errorf("refreshState: %w", err)
+	}
+	defer unlock()
+	tf, _, err := r.Tf.GetTerraformForWorkspace(ctx, ws, tfVersion, "")
 	if err != nil {
 		return fmt.Errorf("refreshState: failed to get terraform executable %s: %w", ws.Name, err)
 	}
+	err = r.Tf.EndRun(ws, tf.WorkingDir(), false)
+	if err != nil {
+		return fmt.Errorf("refreshState: %w", err)
+	}
 
 	ws.Status.NextRefreshTimestamp = metav1.NewTime(time.Now().Add(time.Minute * 5))
 	return r.Client.Status().Update(ctx, &ws)
 }
 
-func (r *WorkspaceReconciler) getEnvsForExecution(ctx context.Context, ws tfreconcilev1alpha1.Workspace) (map[string]string, error) {
+// getEnvsForExecution returns the environment of a run of the workspace: the env of its spec and what its
+// authentication provides. The returned function must be called once the run ends, to clean up what the
+// authentication left on disk.
+func (r *WorkspaceReconciler) getEnvsForExecution(ctx context.Context, ws tfreconcilev1alpha1.Workspace) (map[string]string, func(), error) {
+	envs, err := r.specEnvs(ctx, ws)
+	if err != nil {
+		return nil, nil, err
+	}
+	registryEnvs, err := r.registryCredentialEnvs(ctx, ws)
+	if err != nil {
+		return nil, nil, err
+	}
+	maps.Copy(envs, registryEnvs)
+
+	// Token files are refreshed and Vault leases kept until the run ends and calls cleanup
+	var closers []io.Closer
+	cleanup := func() {
+		for _, c := range closers {
+			if err := c.Close(); err != nil {
+				logf.FromContext(ctx).Error(err, "failed to cleanup authentication files")
+			}
+		}
+	}
+
+	if ws.Spec.Authentication != nil {
+		if ws.Spec.Authentication.AWS != nil {
+			awsEnvs, file, err := r.setupAWSAuthentication(ctx, ws)
+			if err != nil {
+				cleanup()
+				return nil, nil, fmt.Errorf("failed to setup AWS authentication: %w", err)
+			}
+			closers = append(closers, file)
+			maps.Copy(envs, awsEnvs)
+		}
+		if ws.Spec.Authentication.Azure != nil {
+			azureEnvs, file, err := r.setupAzureAuthentication(ctx, ws)
+			if err != nil {
+				cleanup()
+				return nil, nil, fmt.Errorf("failed to setup Azure authentication: %w", err)
+			}
+			closers = append(closers, file)
+			maps.Copy(envs, azureEnvs)
+		}
+		if ws.Spec.Authentication.GCP != nil {
+			gcpEnvs, credentials, err := r.setupGCPAuthentication(ctx, ws)
+			if err != nil {
+				cleanup()
+				return nil, nil, fmt.Errorf("failed to setup GCP authentication: %w", err)
+			}
+			closers = append(closers, credentials)
+			maps.Copy(envs, gcpEnvs)
+		}
+		if ws.Spec.Authentication.Vault != nil {
+			vaultEnvs, lease, err := r.setupVaultAuthentication(ctx, ws)
+			if err != nil {
+				cleanup()
+				return nil, nil, fmt.Errorf("failed to setup Vault authentication: %w", err)
+			}
+			closers = append(closers, lease)
+			maps.Copy(envs, vaultEnvs)
+		}
+		if ws.Spec.Authentication.ServiceAccountToken != nil {
+			tokenEnvs, file, err := r.setupServiceAccountToken(ctx, ws)
+			if err != nil {
+				cleanup()
+				return nil, nil, fmt.Errorf("failed to setup service account token: %w", err)
+			}
+			closers = append(closers, file)
+			maps.Copy(envs, tokenEnvs)
+		}
+	}
+
+	return envs, cleanup, nil
+}
+
+// specEnvs resolves the env of the workspace spec, reading referenced objects with referenceReader.
+func (r *WorkspaceReconciler) specEnvs(ctx context.Context, ws tfreconcilev1alpha1.Workspace) (map[string]string, error) {
 	if ws.Spec.TFExec == nil {
 		return map[string]string{}, nil
 	}
 	if ws.Spec.TFExec.Env == nil {
 		return map[string]string{}, nil
 	}
+
+	var reader client.Reader
 	envs := make(map[string]string)
 	for _, env := range ws.Spec.TFExec.Env {
 		if env.Name == "" {
@@ -259,9 +685,16 @@ func (r *WorkspaceReconciler) getEnvsForExecution(ctx context.Context, ws tfreco
 			envs[env.Name] = env.Value
 			continue
 		}
+		if reader == nil && (env.ConfigMapKeyRef != nil || env.SecretKeyRef != nil) {
+			var err error
+			reader, err = r.referenceReader(ws)
+			if err != nil {
+				return nil, err
+			}
+		}
 		if env.ConfigMapKeyRef != nil {
 			var cm v1.ConfigMap
-			err := r.Client.Get(ctx, client.ObjectKey{Namespace: ws.Namespace, Name: env.ConfigMapKeyRef.Name}, &cm)
+			err := reader.Get(ctx, client.ObjectKey{Namespace: ws.Namespace, Name: env.ConfigMapKeyRef.Name}, &cm)
 			if err != nil {
 				return nil, fmt.Errorf("failed to get configmap %s: %w", env.ConfigMapKeyRef.Name, err)
 			}
@@ -272,7 +705,7 @@ func (r *WorkspaceReconciler) getEnvsForExecution(ctx context.Context, ws tfreco
 		}
 		if env.SecretKeyRef != nil {
 			var secret v1.Secret
-			err := r.Client.Get(ctx, client.ObjectKey{Namespace: ws.Namespace, Name: env.SecretKeyRef.Name}, &secret)
+			err := reader.Get(ctx, client.ObjectKey{Namespace: ws.Namespace, Name: env.SecretKeyRef.Name}, &secret)
 			if err != nil {
 				return nil, fmt.Errorf("failed to get secret %s: %w", env.SecretKeyRef.Name, err)
 			}
@@ -283,56 +716,24 @@ func (r *WorkspaceReconciler) getEnvsForExecution(ctx context.Context, ws tfreco
 		}
 	}
 
-	// Handle AWS authentication with service account tokens
-	if ws.Spec.Authentication != nil {
-		if ws.Spec.Authentication.AWS != nil {
-			if ws.Spec.Authentication.AWS.ServiceAccountName == "" || ws.Spec.Authentication.AWS.RoleARN == "" {
-				tempTokenPath, err := r.setupAWSAuthentication(ctx, ws)
-				if err != nil {
-					return nil, fmt.Errorf("failed to setup AWS authentication: %w", err)
-				}
-
-				envs["AWS_WEB_IDENTITY_TOKEN_FILE"] = tempTokenPath
-				envs["AWS_ROLE_ARN"] = ws.Spec.Authentication.AWS.RoleARN
-			}
-		}
-	}
-
 	return envs, nil
 }
 
-func (r *WorkspaceReconciler) setupAWSAuthentication(ctx context.Context, ws tfreconcilev1alpha1.Workspace) (string, error) {
-	var sa v1.ServiceAccount
-	err := r.Client.Get(ctx, types.NamespacedName{
-		Namespace: ws.Namespace,
-		Name:      ws.Spec.Authentication.AWS.ServiceAccountName,
-	}, &sa)
-	if err != nil {
-		return "", fmt.Errorf("failed to get service account %s in namespace %s: %w",
-			ws.Spec.Authentication.AWS.ServiceAccountName, ws.Namespace, err)
-	}
-
-	tokenRequest := &authv1.TokenRequest{
-		Spec: authv1.TokenRequestSpec{
-			ExpirationSeconds: func(i int64) *int64 { return &i }(600),
-		},
-	}
-
-	err = r.Client.SubResource("token").Create(ctx, &sa, tokenRequest)
-	if err != nil {
-		return "", fmt.Errorf("failed to create token for service account %s: %w",
-			ws.Spec.Authentication.AWS.ServiceAccountName, err)
-	}
-	tokenFile, err := os.CreateTemp("", fmt.Sprintf("aws-token-%s-%s-*", ws.Namespace, ws.Name))
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp token file: %w", err)
-	}
-	defer tokenFile.Close()
-
-	if _, err := tokenFile.Write([]byte(tokenRequest.Status.Token)); err != nil {
-		os.Remove(tokenFile.Name())
-		return "", fmt.Errorf("failed to write token to temp file: %w", err)
+// outputStatuses converts terraform outputs into their status representation, leaving out sensitive values.
+func outputStatuses(outputs map[string]tfexec.OutputMeta) []tfreconcilev1alpha1.OutputStatus {
+	names := slices.Sorted(maps.Keys(outputs))
+	statuses := make([]tfreconcilev1alpha1.OutputStatus, 0, len(names))
+	for _, name := range names {
+		output := outputs[name]
+		status := tfreconcilev1alpha1.OutputStatus{
+			Name:      name,
+			Sensitive: output.Sensitive,
+		}
+		if !output.Sensitive {
+			status.Value = &apiextensionsv1.JSON{Raw: output.Value}
+		}
+		statuses = append(statuses, status)
 	}
 
-	return tokenFile.Name(), nil
+	return statuses
 }
@@ -93,23 +93,35 @@ func TestWorkspace(t *testing.T) {
 		err = wait.For(condition, wait.WithContext(ctx), wait.WithTimeout(10*time.Second))
 		assert.NoError(t, err)
 
-		expectedRender := `terraform {
+		expectedRender := `# versions.tf
+terraform {
+  required_version = "1.11.2"
   required_providers {
     aws = {
       source  = "hashicorp/aws"
       version = "1.0"
     }
   }
+}
+
+# backend.tf
+terraform {
   backend "s3" {
     bucket = "my-bucket"
   }
 }
+
+# providers.tf
 provider "aws" {
 }
+
+# main.tf
 module "my-module" {
   source  = "terraform-aws-modules/vpc/aws"
   version = "5.19.0"
 }
+
+# outputs.tf
 `
 		assert.Equal(t, expectedRender, ws.Status.CurrentRender)
 	})
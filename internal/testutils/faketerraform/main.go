@@ -0,0 +1,255 @@
+// Command faketerraform stands in for the terraform binary in tests. It answers the commands the operator
+// runs through terraform-exec without touching any provider or backend, and is scripted through environment
+// variables, which workspaces can set through spec.tf.env:
+//
+//	FAKE_TERRAFORM_PLAN_CHANGES  "true" makes plans create one resource
+//	FAKE_TERRAFORM_OUTPUTS       a JSON object of output values returned once applied
+//	FAKE_TERRAFORM_INVALID       makes validate report the configuration invalid with the given message
+//	FAKE_TERRAFORM_FAIL          comma separated commands that fail
+//	FAKE_TERRAFORM_HANG          comma separated commands that run until interrupted
+//	FAKE_TERRAFORM_LOG           a file each command run is appended to, one per line
+//
+// The version reported is the name of the directory the binary is in when that is a version, as laid out by
+// BuildFakeTerraform, otherwise 1.11.2.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/hashicorp/go-version"
+)
+
+const defaultVersion = "1.11.2"
+
+func main() {
+	command, args := parseArgs(os.Args[1:])
+	logCommand(command)
+
+	if scripted("FAKE_TERRAFORM_HANG", command) {
+		hang(command)
+	}
+	if scripted("FAKE_TERRAFORM_FAIL", command) {
+		fail("Error: %s failed as scripted", command)
+	}
+
+	switch command {
+	case "version":
+		printVersion(slices.Contains(args, "-json"))
+	case "init":
+		mkdir(".terraform")
+		fmt.Println("Terraform has been successfully initialized!")
+	case "validate":
+		validate()
+	case "plan":
+		plan(args)
+	case "show":
+		show(args)
+	case "apply":
+		writeFile("terraform.tfstate", `{"version": 4}`)
+		fmt.Println("Apply complete!")
+	case "destroy":
+		if err := os.Remove("terraform.tfstate"); err != nil && !os.IsNotExist(err) {
+			fail("Error: %s", err)
+		}
+		fmt.Println("Destroy complete!")
+	case "output":
+		output()
+	case "state push", "force-unlock":
+	default:
+		fail("Error: unsupported command %q", command)
+	}
+}
+
+// parseArgs returns the command, including the subcommand of state, and its arguments.
+func parseArgs(args []string) (string, []string) {
+	var words, rest []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") || len(words) == 2 || (len(words) == 1 && words[0] != "state") {
+			rest = append(rest, arg)
+			continue
+		}
+		words = append(words, arg)
+	}
+	return strings.Join(words, " "), rest
+}
+
+func scripted(env, command string) bool {
+	return slices.Contains(strings.Split(os.Getenv(env), ","), command)
+}
+
+func logCommand(command string) {
+	path := os.Getenv("FAKE_TERRAFORM_LOG")
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		fail("Error: %s", err)
+	}
+	defer f.Close()
+	fmt.Fprintln(f, command)
+}
+
+// hang waits to be interrupted, the way terraform-exec stops commands when their context is done.
+func hang(command string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	fail("Error: %s interrupted", command)
+}
+
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+
+func printVersion(asJSON bool) {
+	v := defaultVersion
+	if dir := filepath.Base(filepath.Dir(os.Args[0])); dir != "" {
+		if _, err := version.NewVersion(dir); err == nil {
+			v = dir
+		}
+	}
+
+	if !asJSON {
+		fmt.Printf("Terraform v%s\n", v)
+		return
+	}
+	printJSON(map[string]any{
+		"terraform_version":   v,
+		"platform":            "linux_amd64",
+		"provider_selections": map[string]string{},
+		"terraform_outdated":  false,
+	})
+}
+
+func validate() {
+	message := os.Getenv("FAKE_TERRAFORM_INVALID")
+	if message == "" {
+		printJSON(map[string]any{"format_version": "1.0", "valid": true, "error_count": 0, "warning_count": 0, "diagnostics": []any{}})
+		return
+	}
+	printJSON(map[string]any{
+		"format_version": "1.0",
+		"valid":          false,
+		"error_count":    1,
+		"warning_count":  0,
+		"diagnostics":    []any{map[string]any{"severity": "error", "summary": message}},
+	})
+}
+
+func plan(args []string) {
+	changes := os.Getenv("FAKE_TERRAFORM_PLAN_CHANGES") == "true"
+	for _, arg := range args {
+		if out, ok := strings.CutPrefix(arg, "-out="); ok {
+			writeFile(out, fmt.Sprint(changes))
+		}
+	}
+
+	if !changes {
+		fmt.Println("No changes. Your infrastructure matches the configuration.")
+		return
+	}
+	fmt.Println("Plan: 1 to add, 0 to change, 0 to destroy.")
+	if slices.Contains(args, "-detailed-exitcode") {
+		os.Exit(2)
+	}
+}
+
+func show(args []string) {
+	var planFile string
+	asJSON := false
+	for _, arg := range args {
+		switch {
+		case arg == "-json":
+			asJSON = true
+		case !strings.HasPrefix(arg, "-"):
+			planFile = arg
+		}
+	}
+	content, err := os.ReadFile(planFile)
+	if err != nil {
+		fail("Error: failed to read plan file: %s", err)
+	}
+	changes := string(content) == "true"
+
+	if !asJSON {
+		if changes {
+			fmt.Println("Plan: 1 to add, 0 to change, 0 to destroy.")
+		} else {
+			fmt.Println("No changes. Your infrastructure matches the configuration.")
+		}
+		return
+	}
+
+	resourceChanges := []any{}
+	if changes {
+		resourceChanges = append(resourceChanges, map[string]any{
+			"address": "terraform_data.fake",
+			"mode":    "managed",
+			"type":    "terraform_data",
+			"name":    "fake",
+			"change":  map[string]any{"actions": []string{"create"}},
+		})
+	}
+	printJSON(map[string]any{"format_version": "1.2", "resource_changes": resourceChanges})
+}
+
+func output() {
+	values := map[string]json.RawMessage{}
+	if _, err := os.Stat("terraform.tfstate"); err == nil && os.Getenv("FAKE_TERRAFORM_OUTPUTS") != "" {
+		err := json.Unmarshal([]byte(os.Getenv("FAKE_TERRAFORM_OUTPUTS")), &values)
+		if err != nil {
+			fail("Error: invalid FAKE_TERRAFORM_OUTPUTS: %s", err)
+		}
+	}
+
+	outputs := map[string]any{}
+	for name, value := range values {
+		outputs[name] = map[string]any{"sensitive": false, "type": valueType(value), "value": value}
+	}
+	printJSON(outputs)
+}
+
+func valueType(value json.RawMessage) string {
+	var v any
+	_ = json.Unmarshal(value, &v)
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	default:
+		return "dynamic"
+	}
+}
+
+func printJSON(v any) {
+	err := json.NewEncoder(os.Stdout).Encode(v)
+	if err != nil {
+		fail("Error: %s", err)
+	}
+}
+
+func mkdir(dir string) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		fail("Error: %s", err)
+	}
+}
+
+func writeFile(path, content string) {
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		fail("Error: %s", err)
+	}
+}
@@ -200,3 +200,15 @@ func WsCurrentGeneration(object k8s.Object) bool {
 	}
 	return workspace.Generation == workspace.Status.ObservedGeneration
 }
+
+// BuildFakeTerraform builds the fake terraform binary of the faketerraform package as the given version,
+// laid out as <dir>/<version>/terraform for a runner.LocalDirInstaller of dir.
+func BuildFakeTerraform(dir, version string) error {
+	cmd := exec.Command("go", "build", "-o", filepath.Join(dir, version, "terraform"), "./internal/testutils/faketerraform")
+	cmd.Dir = RootFolder()
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to build fake terraform: %w: %s", err, out)
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+// Package inspect reads the variables a downloaded terraform module declares, and checks module inputs
+// against them. It also reads the provider versions recorded in dependency lock files.
+package inspect
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Variable is a variable declared by a terraform module.
+type Variable struct {
+	Name string
+	// Type is the type constraint of the variable, cty.DynamicPseudoType if the module doesn't declare one.
+	Type     cty.Type
+	Defaults *typeexpr.Defaults
+	// Default is the default value of the variable, cty.NilVal if it has none.
+	Default  cty.Value
+	Required bool
+}
+
+// TypeString returns the type constraint in terraform syntax.
+func (v Variable) TypeString() string {
+	return typeexpr.TypeString(v.Type)
+}
+
+// DefaultJSON returns the JSON encoding of the default value, or nil if the variable has none.
+func (v Variable) DefaultJSON() ([]byte, error) {
+	if v.Default == cty.NilVal {
+		return nil, nil
+	}
+
+	return ctyjson.Marshal(v.Default, v.Default.Type())
+}
+
+type modulesManifest struct {
+	Modules []struct {
+		Key    string `json:"Key"`
+		Source string `json:"Source"`
+		Dir    string `json:"Dir"`
+	} `json:"Modules"`
+}
+
+// ModuleDir finds the directory terraform init installed the named root module call into.
+func ModuleDir(workingDir, moduleName string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(workingDir, ".terraform", "modules", "modules.json"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read modules manifest: %w", err)
+	}
+
+	var manifest modulesManifest
+	err = json.Unmarshal(content, &manifest)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse modules manifest: %w", err)
+	}
+
+	for _, m := range manifest.Modules {
+		if m.Key != moduleName {
+			continue
+		}
+		if filepath.IsAbs(m.Dir) {
+			return m.Dir, nil
+		}
+		return filepath.Join(workingDir, m.Dir), nil
+	}
+
+	return "", fmt.Errorf("module %s not found in modules manifest", moduleName)
+}
+
+var variableSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "variable", LabelNames: []string{"name"}},
+	},
+}
+
+var variableBodySchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "type"},
+		{Name: "default"},
+	},
+}
+
+// Variables parses the variables declared by the *.tf and *.tf.json files in dir, sorted by name.
+func Variables(dir string) ([]Variable, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read module dir: %w", err)
+	}
+
+	parser := hclparse.NewParser()
+	var vars []Variable
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+
+		var file *hcl.File
+		var diags hcl.Diagnostics
+		switch {
+		case strings.HasSuffix(name, ".tf"):
+			file, diags = parser.ParseHCLFile(filepath.Join(dir, name))
+		case strings.HasSuffix(name, ".tf.json"):
+			file, diags = parser.ParseJSONFile(filepath.Join(dir, name))
+		default:
+			continue
+		}
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("failed to parse %s: %s", name, diags.Error())
+		}
+
+		fileVars, err := fileVariables(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read variables of %s: %w", name, err)
+		}
+		vars = append(vars, fileVars...)
+	}
+
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	return vars, nil
+}
+
+func fileVariables(file *hcl.File) ([]Variable, error) {
+	content, _, diags := file.Body.PartialContent(variableSchema)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	var vars []Variable
+	for _, block := range content.Blocks {
+		attrs, _, diags := block.Body.PartialContent(variableBodySchema)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+
+		v := Variable{
+			Name:     block.Labels[0],
+			Type:     cty.DynamicPseudoType,
+			Default:  cty.NilVal,
+			Required: true,
+		}
+
+		if attr, ok := attrs.Attributes["type"]; ok {
+			v.Type, v.Defaults, diags = typeConstraint(attr.Expr)
+			if diags.HasErrors() {
+				return nil, fmt.Errorf("invalid type of variable %s: %s", v.Name, diags.Error())
+			}
+		}
+
+		if attr, ok := attrs.Attributes["default"]; ok {
+			v.Required = false
+			v.Default, diags = attr.Expr.Value(nil)
+			if diags.HasErrors() {
+				return nil, fmt.Errorf("invalid default of variable %s: %s", v.Name, diags.Error())
+			}
+		}
+
+		vars = append(vars, v)
+	}
+
+	return vars, nil
+}
+
+// typeConstraint reads a type constraint, falling back to parsing the string form used by the JSON syntax.
+func typeConstraint(expr hcl.Expression) (cty.Type, *typeexpr.Defaults, hcl.Diagnostics) {
+	ty, defaults, diags := typeexpr.TypeConstraintWithDefaults(expr)
+	if !diags.HasErrors() {
+		return ty, defaults, diags
+	}
+
+	val, valDiags := expr.Value(nil)
+	if valDiags.HasErrors() || val.Type() != cty.String || val.IsNull() {
+		return ty, defaults, diags
+	}
+
+	parsed, parseDiags := hclsyntax.ParseExpression([]byte(val.AsString()), "", hcl.InitialPos)
+	if parseDiags.HasErrors() {
+		return ty, defaults, diags
+	}
+
+	return typeexpr.TypeConstraintWithDefaults(parsed)
+}
+
+// ProblemReason classifies a problem with the inputs of a module.
+type ProblemReason string
+
+const (
+	// UnknownInput is an input the module doesn't declare a variable for.
+	UnknownInput ProblemReason = "UnknownInput"
+	// MissingInput is a variable without a default that no input is given for.
+	MissingInput ProblemReason = "MissingInput"
+	// TypeMismatch is an input that can't be converted to the type of its variable.
+	TypeMismatch ProblemReason = "TypeMismatch"
+)
+
+// Problem is a problem with a single input of a module.
+type Problem struct {
+	Reason  ProblemReason
+	Input   string
+	Message string
+}
+
+// ValidateInputs checks the JSON encoded inputs against the variables of a module and returns the
+// problems found, sorted by input name.
+func ValidateInputs(vars []Variable, rawInputs []byte) ([]Problem, error) {
+	inputs := map[string]json.RawMessage{}
+	if len(rawInputs) > 0 {
+		err := json.Unmarshal(rawInputs, &inputs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal inputs: %w", err)
+		}
+	}
+
+	byName := make(map[string]Variable, len(vars))
+	for _, v := range vars {
+		byName[v.Name] = v
+	}
+
+	var problems []Problem
+	for name, raw := range inputs {
+		v, ok := byName[name]
+		if !ok {
+			problems = append(problems, Problem{
+				Reason:  UnknownInput,
+				Input:   name,
+				Message: fmt.Sprintf("module has no variable named %q", name),
+			})
+			continue
+		}
+
+		err := checkType(v, raw)
+		if err != nil {
+			problems = append(problems, Problem{
+				Reason:  TypeMismatch,
+				Input:   name,
+				Message: fmt.Sprintf("input %q is not a valid %s: %s", name, v.TypeString(), err),
+			})
+		}
+	}
+
+	for _, v := range vars {
+		if _, ok := inputs[v.Name]; ok || !v.Required {
+			continue
+		}
+		problems = append(problems, Problem{
+			Reason:  MissingInput,
+			Input:   v.Name,
+			Message: fmt.Sprintf("required variable %q has no input", v.Name),
+		})
+	}
+
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Input < problems[j].Input })
+	return problems, nil
+}
+
+func checkType(v Variable, raw json.RawMessage) error {
+	ty, err := ctyjson.ImpliedType(raw)
+	if err != nil {
+		return err
+	}
+	val, err := ctyjson.Unmarshal(raw, ty)
+	if err != nil {
+		return err
+	}
+
+	if v.Defaults != nil {
+		val = v.Defaults.Apply(val)
+	}
+	_, err = convert.Convert(val, v.Type)
+	return err
+}
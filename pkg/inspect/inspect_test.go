@@ -0,0 +1,82 @@
+package inspect
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleDir(t *testing.T) {
+	workingDir := filepath.Join("testdata", "workspace")
+
+	dir, err := ModuleDir(workingDir, "my-module")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(workingDir, ".terraform", "modules", "my-module"), dir)
+
+	_, err = ModuleDir(workingDir, "other-module")
+	assert.Error(t, err)
+}
+
+func TestVariables(t *testing.T) {
+	vars, err := Variables(filepath.Join("testdata", "workspace", ".terraform", "modules", "my-module"))
+	require.NoError(t, err)
+
+	type summary struct {
+		Name     string
+		Type     string
+		Required bool
+		Default  string
+	}
+	var got []summary
+	for _, v := range vars {
+		def, err := v.DefaultJSON()
+		require.NoError(t, err)
+		got = append(got, summary{Name: v.Name, Type: v.TypeString(), Required: v.Required, Default: string(def)})
+	}
+
+	assert.Equal(t, []summary{
+		{Name: "anything", Type: "any", Required: true},
+		{Name: "azs", Type: "list(string)", Default: "[]"},
+		{Name: "count_per_az", Type: "number", Default: "2"},
+		{Name: "name", Type: "string", Required: true},
+		{Name: "settings", Type: "object({enabled=bool,size=number})", Default: "null"},
+		{Name: "tags", Type: "map(string)", Default: `{"managed-by":"krec"}`},
+	}, got)
+}
+
+func TestValidateInputs(t *testing.T) {
+	vars, err := Variables(filepath.Join("testdata", "workspace", ".terraform", "modules", "my-module"))
+	require.NoError(t, err)
+
+	t.Run("valid", func(t *testing.T) {
+		problems, err := ValidateInputs(vars, []byte(`{
+			"name": "my-name",
+			"anything": {"nested": [1, 2]},
+			"azs": ["eu-west-1a"],
+			"count_per_az": "3",
+			"settings": {"enabled": true}
+		}`))
+		require.NoError(t, err)
+		assert.Empty(t, problems)
+	})
+
+	t.Run("problems", func(t *testing.T) {
+		problems, err := ValidateInputs(vars, []byte(`{
+			"nmae": "typo",
+			"azs": "eu-west-1a",
+			"settings": {"size": 3}
+		}`))
+		require.NoError(t, err)
+
+		var reasons []ProblemReason
+		var inputs []string
+		for _, p := range problems {
+			reasons = append(reasons, p.Reason)
+			inputs = append(inputs, p.Input)
+		}
+		assert.Equal(t, []string{"anything", "azs", "name", "nmae", "settings"}, inputs)
+		assert.Equal(t, []ProblemReason{MissingInput, TypeMismatch, MissingInput, UnknownInput, TypeMismatch}, reasons)
+	})
+}
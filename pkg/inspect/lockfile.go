@@ -0,0 +1,66 @@
+package inspect
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// LockFileName is the name of the dependency lock file terraform writes into the workspace directory.
+const LockFileName = ".terraform.lock.hcl"
+
+// LockedProviders reads the provider versions recorded in a dependency lock file, keyed by provider address.
+func LockedProviders(content []byte) (map[string]string, error) {
+	providers := map[string]string{}
+	if len(content) == 0 {
+		return providers, nil
+	}
+
+	f, diags := hclsyntax.ParseConfig(content, LockFileName, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse lock file: %w", diags)
+	}
+
+	for _, block := range f.Body.(*hclsyntax.Body).Blocks {
+		if block.Type != "provider" || len(block.Labels) != 1 {
+			continue
+		}
+		attr, ok := block.Body.Attributes["version"]
+		if !ok {
+			continue
+		}
+		v, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() || !v.Type().Equals(cty.String) {
+			return nil, fmt.Errorf("invalid version of provider %s in lock file", block.Labels[0])
+		}
+		providers[block.Labels[0]] = v.AsString()
+	}
+
+	return providers, nil
+}
+
+// ProviderChanges describes how the locked providers changed, formatted as "<address>: <old> -> <new>"
+// and sorted by address. Added and removed providers have "none" as their old or new version.
+func ProviderChanges(before, after map[string]string) []string {
+	var changes []string
+	for addr, newVersion := range after {
+		oldVersion, ok := before[addr]
+		if !ok {
+			oldVersion = "none"
+		}
+		if oldVersion != newVersion {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", addr, oldVersion, newVersion))
+		}
+	}
+	for addr, oldVersion := range before {
+		if _, ok := after[addr]; !ok {
+			changes = append(changes, fmt.Sprintf("%s: %s -> none", addr, oldVersion))
+		}
+	}
+	sort.Strings(changes)
+
+	return changes
+}
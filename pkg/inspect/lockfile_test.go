@@ -0,0 +1,60 @@
+package inspect
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const lockFile = `# This file is maintained automatically by "terraform init".
+# Manual edits may be lost in future updates.
+
+provider "registry.terraform.io/hashicorp/aws" {
+  version     = "5.0.0"
+  constraints = "~> 5.0"
+  hashes = [
+    "h1:AAAA=",
+  ]
+}
+
+provider "registry.terraform.io/hashicorp/null" {
+  version = "3.2.3"
+}
+`
+
+func TestLockedProviders(t *testing.T) {
+	providers, err := LockedProviders([]byte(lockFile))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"registry.terraform.io/hashicorp/aws":  "5.0.0",
+		"registry.terraform.io/hashicorp/null": "3.2.3",
+	}, providers)
+
+	providers, err = LockedProviders(nil)
+	require.NoError(t, err)
+	assert.Empty(t, providers)
+
+	_, err = LockedProviders([]byte(`provider "x" {`))
+	assert.Error(t, err)
+}
+
+func TestProviderChanges(t *testing.T) {
+	changes := ProviderChanges(
+		map[string]string{
+			"registry.terraform.io/hashicorp/aws":    "5.0.0",
+			"registry.terraform.io/hashicorp/null":   "3.2.3",
+			"registry.terraform.io/hashicorp/random": "3.6.0",
+		},
+		map[string]string{
+			"registry.terraform.io/hashicorp/aws":  "5.1.0",
+			"registry.terraform.io/hashicorp/null": "3.2.3",
+			"registry.terraform.io/hashicorp/tls":  "4.0.0",
+		},
+	)
+	assert.Equal(t, []string{
+		"registry.terraform.io/hashicorp/aws: 5.0.0 -> 5.1.0",
+		"registry.terraform.io/hashicorp/random: 3.6.0 -> none",
+		"registry.terraform.io/hashicorp/tls: none -> 4.0.0",
+	}, changes)
+}
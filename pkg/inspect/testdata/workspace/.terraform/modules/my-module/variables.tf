@@ -0,0 +1,27 @@
+variable "name" {
+  description = "Name of the resources"
+  type        = string
+}
+
+variable "azs" {
+  type    = list(string)
+  default = []
+}
+
+variable "tags" {
+  type = map(string)
+  default = {
+    managed-by = "krec"
+  }
+}
+
+variable "settings" {
+  type = object({
+    enabled = bool
+    size    = optional(number, 1)
+  })
+  default = null
+}
+
+variable "anything" {
+}
@@ -1,5 +1,7 @@
 package operator
 
+import "lukaspj.io/kube-tf-reconciler/pkg/runner"
+
 type Config struct {
 	Port                 string
 	ProbeAddr            string
@@ -9,6 +11,42 @@ type Config struct {
 	LeaderElectionID     string
 	EnableLeaderElection bool
 	WorkspacePath        string
+	TofuMirror           string
+	TofuIndex            string
+	// TofuPublicKeyFile is an armored PGP key the checksums of the tofu mirror are signed with, such as the
+	// OpenTofu release key. OpenTofu can't be installed without it.
+	TofuPublicKeyFile string
+
+	// TerraformDir holds terraform binaries laid out as <dir>/<version>/terraform, used instead of downloading them.
+	TerraformDir string
+	// TerraformMirror is the base URL of a mirror of releases.hashicorp.com to download terraform from.
+	TerraformMirror string
+	// TerraformMirrorPublicKeyFile is an armored PGP key the mirror checksums are signed with, defaults to the HashiCorp key.
+	TerraformMirrorPublicKeyFile string
+	// ProviderNetworkMirror is the URL of a provider network mirror injected into every workspace.
+	ProviderNetworkMirror string
+	// ProviderFilesystemMirror is the path of a provider filesystem mirror injected into every workspace.
+	ProviderFilesystemMirror string
+	// PluginCacheMaxSize is a quantity such as 10Gi the shared provider plugin cache is trimmed to. Empty means unbounded.
+	PluginCacheMaxSize string
+	// RefreshInterval is a duration such as 30m after which workspaces are planned again to detect drift. Empty disables refreshes.
+	RefreshInterval string
+	// ShutdownGracePeriod is a duration such as 5m runs in flight are given to finish when the operator shuts down,
+	// after which terraform is interrupted.
+	ShutdownGracePeriod string
+	// JanitorInterval is a duration such as 10m between runs of the janitor, which removes what deleted workspaces
+	// left on disk and reports disk usage. Empty disables the janitor.
+	JanitorInterval string
+
+	// Storage is where workspace directories are persisted, either "local" or "s3". Local keeps them on the
+	// volume WorkspacePath is on, which should be a PersistentVolumeClaim for them to survive restarts.
+	Storage string
+	// S3Endpoint is the URL of the S3 compatible object store, such as http://minio:9000. Credentials are
+	// read from the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables or the instance metadata.
+	S3Endpoint string
+	S3Region   string
+	S3Bucket   string
+	S3Prefix   string
 }
 
 func DefaultConfig() Config {
@@ -19,5 +57,10 @@ func DefaultConfig() Config {
 		Namespace:            "krec",
 		EnableLeaderElection: false,
 		WorkspacePath:        "./.testdata",
+		TofuMirror:           runner.DefaultTofuMirror,
+		TofuIndex:            runner.DefaultTofuIndex,
+		Storage:              "local",
+		ShutdownGracePeriod:  "5m",
+		JanitorInterval:      "10m",
 	}
 }
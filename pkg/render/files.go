@@ -0,0 +1,172 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+// Format is the terraform syntax a workspace is rendered in.
+type Format string
+
+const (
+	// FormatHCL renders the native terraform syntax into *.tf files.
+	FormatHCL Format = "hcl"
+	// FormatJSON renders the JSON terraform syntax into *.tf.json files.
+	FormatJSON Format = "json"
+)
+
+// File is a single rendered terraform configuration file.
+type File struct {
+	Name    string
+	Content []byte
+}
+
+// fileRenderer renders the HCL content of one file in the layout.
+type fileRenderer func(body *hclwrite.Body, ws tfreconcilev1alpha1.Workspace) error
+
+// layout lists the files of a rendered workspace in the order they are written.
+var layout = []struct {
+	name string
+	hcl  fileRenderer
+	json jsonRenderer
+}{
+	{
+		name: "versions",
+		hcl:  Versions,
+		json: jsonVersions,
+	},
+	{
+		name: "backend",
+		hcl: func(body *hclwrite.Body, ws tfreconcilev1alpha1.Workspace) error {
+			return Backend(body, ws.Spec.Backend)
+		},
+		json: jsonBackend,
+	},
+	{
+		name: "providers",
+		hcl: func(body *hclwrite.Body, ws tfreconcilev1alpha1.Workspace) error {
+			return Providers(body, ws.Spec.ProviderSpecs)
+		},
+		json: jsonProviders,
+	},
+	{
+		name: "main",
+		hcl: func(body *hclwrite.Body, ws tfreconcilev1alpha1.Workspace) error {
+			err := Module(body, ws.Spec.Module)
+			if err != nil {
+				return err
+			}
+			err = Imports(body, ws.Spec.Imports)
+			if err != nil {
+				return err
+			}
+			err = Moved(body, ws.Spec.Moved)
+			if err != nil {
+				return err
+			}
+			return Removed(body, ws.Spec.Removed)
+		},
+		json: jsonModule,
+	},
+	{
+		name: "outputs",
+		hcl: func(body *hclwrite.Body, ws tfreconcilev1alpha1.Workspace) error {
+			return Outputs(body, ws.Spec.Module)
+		},
+		json: jsonOutputs,
+	},
+}
+
+// Files renders the workspace into the conventional versions, backend, providers, main and outputs files.
+func Files(ws tfreconcilev1alpha1.Workspace, format Format) ([]File, error) {
+	if ws.Spec.Module == nil {
+		return nil, fmt.Errorf("workspace has no module")
+	}
+
+	var files []File
+	for _, l := range layout {
+		var file File
+		var err error
+		switch format {
+		case FormatHCL, "":
+			file, err = renderHCLFile(l.name+".tf", l.hcl, ws)
+		case FormatJSON:
+			file, err = renderJSONFile(l.name+".tf.json", l.json, ws)
+		default:
+			return nil, fmt.Errorf("unsupported render format %q", format)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", l.name, err)
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+func renderHCLFile(name string, r fileRenderer, ws tfreconcilev1alpha1.Workspace) (File, error) {
+	f := hclwrite.NewEmptyFile()
+	err := r(f.Body(), ws)
+	if err != nil {
+		return File{}, err
+	}
+
+	return File{Name: name, Content: f.Bytes()}, nil
+}
+
+// Concat joins the files into a single document, each file preceded by a comment holding its name.
+func Concat(files []File) []byte {
+	var buf bytes.Buffer
+	for i, f := range files {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "# %s\n", f.Name)
+		buf.Write(f.Content)
+	}
+
+	return buf.Bytes()
+}
+
+// Write writes the files to dir and removes any other terraform configuration files left over from
+// earlier renders, so that switching format or dropping a file doesn't leave stale configuration behind.
+func Write(dir string, files []File) error {
+	keep := make(map[string]bool, len(files))
+	for _, f := range files {
+		keep[f.Name] = true
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read dir %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || keep[name] || !isConfigFile(name) {
+			continue
+		}
+		err = os.Remove(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("failed to remove stale file %s: %w", name, err)
+		}
+	}
+
+	for _, f := range files {
+		err = os.WriteFile(filepath.Join(dir, f.Name), f.Content, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func isConfigFile(name string) bool {
+	return strings.HasSuffix(name, ".tf") || strings.HasSuffix(name, ".tf.json")
+}
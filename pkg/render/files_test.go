@@ -0,0 +1,162 @@
+package render
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+	"lukaspj.io/kube-tf-reconciler/internal/testutils"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func goldenWorkspaces() map[string]tfreconcilev1alpha1.Workspace {
+	return map[string]tfreconcilev1alpha1.Workspace{
+		"basic": {
+			Spec: tfreconcilev1alpha1.WorkspaceSpec{
+				TerraformVersion: "1.11.2",
+				Backend: tfreconcilev1alpha1.BackendSpec{
+					Type: "s3",
+					Inputs: testutils.Json(map[string]interface{}{
+						"bucket": "my-bucket",
+						"key":    "state.tfstate",
+					}),
+				},
+				ProviderSpecs: []tfreconcilev1alpha1.ProviderSpec{
+					{
+						Name:    "aws",
+						Source:  "hashicorp/aws",
+						Version: ">= 5.40.0",
+					},
+				},
+				Module: &tfreconcilev1alpha1.ModuleSpec{
+					Name:    "my-module",
+					Source:  "terraform-aws-modules/vpc/aws",
+					Version: "5.19.0",
+					Inputs: testutils.Json(map[string]interface{}{
+						"name": "my-vpc",
+						"azs":  []string{"eu-west-1a", "eu-west-1b"},
+						"tags": map[string]interface{}{
+							"team": "platform",
+						},
+					}),
+					Outputs: []tfreconcilev1alpha1.ModuleOutput{
+						{Name: "vpc_id", Value: "vpc_id"},
+						{Name: "private_subnets", Value: "private_subnets", Sensitive: true},
+					},
+				},
+				Imports: []tfreconcilev1alpha1.ImportSpec{
+					{To: `module.my-module.aws_vpc.this[0]`, ID: "vpc-0123456789"},
+				},
+				Moved: []tfreconcilev1alpha1.MovedSpec{
+					{From: "module.my-module.aws_subnet.old", To: "module.my-module.aws_subnet.new"},
+				},
+				Removed: []tfreconcilev1alpha1.RemovedSpec{
+					{Address: "module.my-module.aws_eip.nat"},
+				},
+			},
+		},
+		"escaping": {
+			Spec: tfreconcilev1alpha1.WorkspaceSpec{
+				Backend: tfreconcilev1alpha1.BackendSpec{
+					Type: "s3",
+					Inputs: &apiextensionsv1.JSON{Raw: []byte(`{
+						"bucket": "my-bucket",
+						"assume_role": {"role_arn": "arn:aws:iam::123456789012:role/state"},
+						"allowed_account_ids": ["123456789012"]
+					}`)},
+				},
+				Module: &tfreconcilev1alpha1.ModuleSpec{
+					Name:   "my-module",
+					Source: "./my-module",
+					Inputs: &apiextensionsv1.JSON{Raw: []byte(`{
+						"template": "${var.not_a_reference}",
+						"directive": "%{ if true }literal%{ endif }",
+						"quoted": "say \"hi\"\nand <bye>",
+						"big": 12345678901234567890,
+						"ratio": 0.1,
+						"mixed": [1, "two", null, {"nested": [3.5e2]}],
+						"unset": null
+					}`)},
+				},
+			},
+		},
+	}
+}
+
+func TestFilesGolden(t *testing.T) {
+	for name, ws := range goldenWorkspaces() {
+		for _, format := range []Format{FormatHCL, FormatJSON} {
+			t.Run(name+"/"+string(format), func(t *testing.T) {
+				files, err := Files(ws, format)
+				require.NoError(t, err)
+
+				dir := filepath.Join("testdata", name)
+				for _, f := range files {
+					golden := filepath.Join(dir, f.Name)
+					if *update {
+						require.NoError(t, os.MkdirAll(dir, 0755))
+						require.NoError(t, os.WriteFile(golden, f.Content, 0644))
+					}
+
+					expected, err := os.ReadFile(golden)
+					require.NoError(t, err)
+					assert.Equal(t, string(expected), string(f.Content), f.Name)
+				}
+			})
+		}
+	}
+}
+
+func TestFilesLayout(t *testing.T) {
+	ws := goldenWorkspaces()["basic"]
+
+	hcl, err := Files(ws, FormatHCL)
+	require.NoError(t, err)
+	json, err := Files(ws, FormatJSON)
+	require.NoError(t, err)
+
+	var hclNames, jsonNames []string
+	for _, f := range hcl {
+		hclNames = append(hclNames, f.Name)
+	}
+	for _, f := range json {
+		jsonNames = append(jsonNames, f.Name)
+	}
+	assert.Equal(t, []string{"versions.tf", "backend.tf", "providers.tf", "main.tf", "outputs.tf"}, hclNames)
+	assert.Equal(t, []string{"versions.tf.json", "backend.tf.json", "providers.tf.json", "main.tf.json", "outputs.tf.json"}, jsonNames)
+}
+
+func TestFilesUnsupportedFormat(t *testing.T) {
+	_, err := Files(goldenWorkspaces()["basic"], Format("yaml"))
+	assert.Error(t, err)
+}
+
+func TestWriteRemovesStaleFiles(t *testing.T) {
+	dir := t.TempDir()
+	ws := goldenWorkspaces()["basic"]
+
+	hcl, err := Files(ws, FormatHCL)
+	require.NoError(t, err)
+	require.NoError(t, Write(dir, hcl))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "terraform.tfstate"), []byte("{}"), 0644))
+
+	json, err := Files(ws, FormatJSON)
+	require.NoError(t, err)
+	require.NoError(t, Write(dir, json))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{
+		"backend.tf.json", "main.tf.json", "outputs.tf.json", "providers.tf.json", "versions.tf.json", "terraform.tfstate",
+	}, names)
+}
@@ -0,0 +1,233 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+// jsonRenderer renders the JSON syntax content of one file in the layout.
+type jsonRenderer func(ws tfreconcilev1alpha1.Workspace) (map[string]any, error)
+
+func renderJSONFile(name string, r jsonRenderer, ws tfreconcilev1alpha1.Workspace) (File, error) {
+	doc, err := r(ws)
+	if err != nil {
+		return File{}, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(doc)
+	if err != nil {
+		return File{}, fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+
+	return File{Name: name, Content: buf.Bytes()}, nil
+}
+
+func jsonVersions(ws tfreconcilev1alpha1.Workspace) (map[string]any, error) {
+	terraform := map[string]any{}
+	if ws.Spec.TerraformVersion != "" {
+		terraform["required_version"] = ws.Spec.TerraformVersion
+	}
+
+	if len(ws.Spec.ProviderSpecs) > 0 {
+		requiredProviders := map[string]any{}
+		for _, p := range ws.Spec.ProviderSpecs {
+			requiredProviders[p.Name] = map[string]any{
+				"source":  p.Source,
+				"version": p.Version,
+			}
+		}
+		terraform["required_providers"] = requiredProviders
+	}
+
+	return map[string]any{"terraform": terraform}, nil
+}
+
+func jsonBackend(ws tfreconcilev1alpha1.Workspace) (map[string]any, error) {
+	inputs, err := jsonInputs(ws.Spec.Backend.Inputs)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal inputs: %w", err)
+	}
+
+	return map[string]any{
+		"terraform": map[string]any{
+			"backend": map[string]any{
+				ws.Spec.Backend.Type: inputs,
+			},
+		},
+	}, nil
+}
+
+func jsonProviders(ws tfreconcilev1alpha1.Workspace) (map[string]any, error) {
+	if len(ws.Spec.ProviderSpecs) == 0 {
+		return map[string]any{}, nil
+	}
+
+	providers := map[string]any{}
+	for _, p := range ws.Spec.ProviderSpecs {
+		providers[p.Name] = map[string]any{}
+	}
+
+	return map[string]any{"provider": providers}, nil
+}
+
+func jsonModule(ws tfreconcilev1alpha1.Workspace) (map[string]any, error) {
+	m := ws.Spec.Module
+	module, err := jsonInputs(m.Inputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal inputs: %w", err)
+	}
+
+	module["source"] = m.Source
+	if m.Version != "" {
+		module["version"] = m.Version
+	}
+
+	doc := map[string]any{
+		"module": map[string]any{
+			m.Name: module,
+		},
+	}
+
+	err = jsonStateBlocks(doc, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// jsonStateBlocks adds the import, moved and removed blocks to doc. Addresses are validated the same way
+// as for HCL, terraform then reads the JSON strings as references.
+func jsonStateBlocks(doc map[string]any, ws tfreconcilev1alpha1.Workspace) error {
+	var imports []any
+	for _, i := range ws.Spec.Imports {
+		_, err := parseAddress(i.To)
+		if err != nil {
+			return fmt.Errorf("invalid import address: %w", err)
+		}
+		imports = append(imports, map[string]any{
+			"to": i.To,
+			"id": templateEscaper.Replace(i.ID),
+		})
+	}
+	if len(imports) > 0 {
+		doc["import"] = imports
+	}
+
+	var moved []any
+	for _, m := range ws.Spec.Moved {
+		_, err := parseAddress(m.From)
+		if err != nil {
+			return fmt.Errorf("invalid moved from address: %w", err)
+		}
+		_, err = parseAddress(m.To)
+		if err != nil {
+			return fmt.Errorf("invalid moved to address: %w", err)
+		}
+		moved = append(moved, map[string]any{
+			"from": m.From,
+			"to":   m.To,
+		})
+	}
+	if len(moved) > 0 {
+		doc["moved"] = moved
+	}
+
+	var removed []any
+	for _, r := range ws.Spec.Removed {
+		_, err := parseAddress(r.Address)
+		if err != nil {
+			return fmt.Errorf("invalid removed address: %w", err)
+		}
+		removed = append(removed, map[string]any{
+			"from": r.Address,
+			"lifecycle": map[string]any{
+				"destroy": r.Destroy,
+			},
+		})
+	}
+	if len(removed) > 0 {
+		doc["removed"] = removed
+	}
+
+	return nil
+}
+
+func jsonOutputs(ws tfreconcilev1alpha1.Workspace) (map[string]any, error) {
+	m := ws.Spec.Module
+	if len(m.Outputs) == 0 {
+		return map[string]any{}, nil
+	}
+
+	outputs := map[string]any{}
+	for _, o := range m.Outputs {
+		_, err := outputTraversal(m.Name, o.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for output %s: %w", o.Name, err)
+		}
+		output := map[string]any{
+			"value": fmt.Sprintf("${module.%s.%s}", m.Name, o.Value),
+		}
+		if o.Sensitive {
+			output["sensitive"] = true
+		}
+		outputs[o.Name] = output
+	}
+
+	return map[string]any{"output": outputs}, nil
+}
+
+// jsonInputs decodes user supplied inputs, keeping numbers verbatim and escaping template sequences so
+// strings reach terraform as literals, the same way hclwrite escapes them in the HCL syntax.
+func jsonInputs(raw *apiextensionsv1.JSON) (map[string]any, error) {
+	inputs := map[string]any{}
+	if raw == nil {
+		return inputs, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw.Raw))
+	dec.UseNumber()
+	err := dec.Decode(&inputs)
+	if err != nil {
+		return nil, err
+	}
+	if inputs == nil {
+		inputs = map[string]any{}
+	}
+
+	for k, v := range inputs {
+		inputs[k] = escapeTemplates(v)
+	}
+
+	return inputs, nil
+}
+
+var templateEscaper = strings.NewReplacer("${", "$${", "%{", "%%{")
+
+func escapeTemplates(value any) any {
+	switch v := value.(type) {
+	case string:
+		return templateEscaper.Replace(v)
+	case map[string]any:
+		for k, val := range v {
+			v[k] = escapeTemplates(val)
+		}
+		return v
+	case []any:
+		for i, val := range v {
+			v[i] = escapeTemplates(val)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -1,6 +1,7 @@
 package render
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"maps"
@@ -23,51 +24,82 @@ func Module(body *hclwrite.Body, m *tfreconcilev1alpha1.ModuleSpec) error {
 	}
 
 	if m.Inputs != nil {
-		var inputs map[string]interface{}
-		err := json.Unmarshal(m.Inputs.Raw, &inputs)
+		inputs, err := decodeInputs(m.Inputs.Raw)
 		if err != nil {
 			return fmt.Errorf("failed to unmarshal inputs: %w", err)
 		}
 
 		// Map the inputs to the module body
-		mapInputsToModuleBody(moduleBlock.Body(), inputs)
+		err = mapInputsToBody(moduleBlock.Body(), inputs)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func mapInputsToModuleBody(body *hclwrite.Body, inputs map[string]interface{}) {
+// decodeInputs decodes user supplied inputs, keeping numbers as json.Number so they convert to cty
+// without losing precision.
+func decodeInputs(raw []byte) (map[string]interface{}, error) {
+	var inputs map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	err := dec.Decode(&inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	return inputs, nil
+}
+
+func mapInputsToBody(body *hclwrite.Body, inputs map[string]interface{}) error {
 	keys := slices.Collect(maps.Keys(inputs))
 	sort.Strings(keys)
 	for _, key := range keys {
-		value := convertToCtyValue(inputs[key])
-		if !value.IsNull() {
-			body.SetAttributeValue(key, value)
+		value, err := convertToCtyValue(inputs[key])
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %w", key, err)
 		}
+		body.SetAttributeValue(key, value)
 	}
+
+	return nil
 }
 
-func convertToCtyValue(value interface{}) cty.Value {
+// convertToCtyValue converts a decoded JSON value to cty. Arrays become tuples, since JSON arrays may
+// mix element types, and null stays null so both render formats pass the same configuration.
+func convertToCtyValue(value interface{}) (cty.Value, error) {
 	switch v := value.(type) {
+	case nil:
+		return cty.NullVal(cty.DynamicPseudoType), nil
 	case string:
-		return cty.StringVal(v)
-	case float64:
-		return cty.NumberFloatVal(v)
+		return cty.StringVal(v), nil
+	case json.Number:
+		return cty.ParseNumberVal(v.String())
 	case bool:
-		return cty.BoolVal(v)
+		return cty.BoolVal(v), nil
 	case map[string]interface{}:
 		m := map[string]cty.Value{}
 		for key, val := range v {
-			m[key] = convertToCtyValue(val)
+			cv, err := convertToCtyValue(val)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			m[key] = cv
 		}
-		return cty.ObjectVal(m)
+		return cty.ObjectVal(m), nil
 	case []interface{}:
-		var list []cty.Value
+		list := make([]cty.Value, 0, len(v))
 		for _, item := range v {
-			list = append(list, convertToCtyValue(item))
+			cv, err := convertToCtyValue(item)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			list = append(list, cv)
 		}
-		return cty.ListVal(list)
+		return cty.TupleVal(list), nil
 	default:
-		return cty.NilVal
+		return cty.NilVal, fmt.Errorf("unsupported type %T", v)
 	}
 }
@@ -0,0 +1,45 @@
+package render
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+// Outputs renders a root output block for each declared output of the module.
+func Outputs(body *hclwrite.Body, m *tfreconcilev1alpha1.ModuleSpec) error {
+	for _, o := range m.Outputs {
+		value, err := outputTraversal(m.Name, o.Value)
+		if err != nil {
+			return fmt.Errorf("invalid value for output %s: %w", o.Name, err)
+		}
+
+		outputBlock := body.AppendNewBlock("output", []string{o.Name})
+		outputBlock.Body().SetAttributeTraversal("value", value)
+		if o.Sensitive {
+			outputBlock.Body().SetAttributeValue("sensitive", cty.True)
+		}
+	}
+
+	return nil
+}
+
+// outputTraversal parses value, such as vpc_id or subnets[0].id, relative to the module and returns the
+// absolute traversal module.<module>.<value>.
+func outputTraversal(module, value string) (hcl.Traversal, error) {
+	rel, err := parseAddress(value)
+	if err != nil {
+		return nil, err
+	}
+
+	traversal := hcl.Traversal{
+		hcl.TraverseRoot{Name: "module"},
+		hcl.TraverseAttr{Name: module},
+		hcl.TraverseAttr{Name: rel.RootName()},
+	}
+
+	return append(traversal, rel[1:]...), nil
+}
@@ -0,0 +1,49 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+func TestOutputs(t *testing.T) {
+	f := hclwrite.NewEmptyFile()
+
+	expected := `output "vpc_id" {
+  value = module.my-module.vpc_id
+}
+output "db_password" {
+  value     = module.my-module.password
+  sensitive = true
+}
+output "first_subnet" {
+  value = module.my-module.subnets[0].id
+}
+`
+
+	err := Outputs(f.Body(), &tfreconcilev1alpha1.ModuleSpec{
+		Name:   "my-module",
+		Source: "./my-module",
+		Outputs: []tfreconcilev1alpha1.ModuleOutput{
+			{Name: "vpc_id", Value: "vpc_id"},
+			{Name: "db_password", Value: "password", Sensitive: true},
+			{Name: "first_subnet", Value: "subnets[0].id"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(f.Bytes()))
+}
+
+func TestOutputsInvalidValue(t *testing.T) {
+	for _, value := range []string{"", "vpc id", "var.x }", `"quoted"`} {
+		f := hclwrite.NewEmptyFile()
+		err := Outputs(f.Body(), &tfreconcilev1alpha1.ModuleSpec{
+			Name:    "my-module",
+			Source:  "./my-module",
+			Outputs: []tfreconcilev1alpha1.ModuleOutput{{Name: "bad", Value: value}},
+		})
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,73 @@
+package render
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+// Imports renders an import block for each resource to import into the state.
+func Imports(body *hclwrite.Body, imports []tfreconcilev1alpha1.ImportSpec) error {
+	for _, i := range imports {
+		to, err := parseAddress(i.To)
+		if err != nil {
+			return fmt.Errorf("invalid import address: %w", err)
+		}
+
+		importBlock := body.AppendNewBlock("import", nil)
+		importBlock.Body().SetAttributeTraversal("to", to)
+		importBlock.Body().SetAttributeValue("id", cty.StringVal(i.ID))
+	}
+
+	return nil
+}
+
+// Moved renders a moved block for each address change.
+func Moved(body *hclwrite.Body, moved []tfreconcilev1alpha1.MovedSpec) error {
+	for _, m := range moved {
+		from, err := parseAddress(m.From)
+		if err != nil {
+			return fmt.Errorf("invalid moved from address: %w", err)
+		}
+		to, err := parseAddress(m.To)
+		if err != nil {
+			return fmt.Errorf("invalid moved to address: %w", err)
+		}
+
+		movedBlock := body.AppendNewBlock("moved", nil)
+		movedBlock.Body().SetAttributeTraversal("from", from)
+		movedBlock.Body().SetAttributeTraversal("to", to)
+	}
+
+	return nil
+}
+
+// Removed renders a removed block for each address to remove from the state.
+func Removed(body *hclwrite.Body, removed []tfreconcilev1alpha1.RemovedSpec) error {
+	for _, r := range removed {
+		from, err := parseAddress(r.Address)
+		if err != nil {
+			return fmt.Errorf("invalid removed address: %w", err)
+		}
+
+		removedBlock := body.AppendNewBlock("removed", nil)
+		removedBlock.Body().SetAttributeTraversal("from", from)
+		lifecycleBlock := removedBlock.Body().AppendNewBlock("lifecycle", nil)
+		lifecycleBlock.Body().SetAttributeValue("destroy", cty.BoolVal(r.Destroy))
+	}
+
+	return nil
+}
+
+func parseAddress(address string) (hcl.Traversal, error) {
+	traversal, diags := hclsyntax.ParseTraversalAbs([]byte(address), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("%q: %s", address, diags.Error())
+	}
+
+	return traversal, nil
+}
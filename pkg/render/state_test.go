@@ -0,0 +1,56 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+func TestStateBlocks(t *testing.T) {
+	f := hclwrite.NewEmptyFile()
+
+	expected := `import {
+  to = module.my-module.aws_s3_bucket.this["logs"]
+  id = "my-logs-bucket"
+}
+moved {
+  from = module.my-module.aws_iam_role.old
+  to   = module.my-module.aws_iam_role.new
+}
+removed {
+  from = module.my-module.aws_instance.legacy
+  lifecycle {
+    destroy = false
+  }
+}
+`
+
+	err := Imports(f.Body(), []tfreconcilev1alpha1.ImportSpec{
+		{To: `module.my-module.aws_s3_bucket.this["logs"]`, ID: "my-logs-bucket"},
+	})
+	assert.NoError(t, err)
+	err = Moved(f.Body(), []tfreconcilev1alpha1.MovedSpec{
+		{From: "module.my-module.aws_iam_role.old", To: "module.my-module.aws_iam_role.new"},
+	})
+	assert.NoError(t, err)
+	err = Removed(f.Body(), []tfreconcilev1alpha1.RemovedSpec{
+		{Address: "module.my-module.aws_instance.legacy"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(f.Bytes()))
+}
+
+func TestStateBlocksInvalidAddress(t *testing.T) {
+	f := hclwrite.NewEmptyFile()
+
+	err := Imports(f.Body(), []tfreconcilev1alpha1.ImportSpec{
+		{To: "module.my-module.aws_s3_bucket.this[", ID: "my-logs-bucket"},
+	})
+	assert.Error(t, err)
+	err = Moved(f.Body(), []tfreconcilev1alpha1.MovedSpec{
+		{From: "${var.nope}", To: "aws_iam_role.new"},
+	})
+	assert.Error(t, err)
+}
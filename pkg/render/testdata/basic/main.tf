@@ -0,0 +1,23 @@
+module "my-module" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.19.0"
+  azs     = ["eu-west-1a", "eu-west-1b"]
+  name    = "my-vpc"
+  tags = {
+    team = "platform"
+  }
+}
+import {
+  to = module.my-module.aws_vpc.this[0]
+  id = "vpc-0123456789"
+}
+moved {
+  from = module.my-module.aws_subnet.old
+  to   = module.my-module.aws_subnet.new
+}
+removed {
+  from = module.my-module.aws_eip.nat
+  lifecycle {
+    destroy = false
+  }
+}
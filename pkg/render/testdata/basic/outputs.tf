@@ -0,0 +1,7 @@
+output "vpc_id" {
+  value = module.my-module.vpc_id
+}
+output "private_subnets" {
+  value     = module.my-module.private_subnets
+  sensitive = true
+}
@@ -0,0 +1,9 @@
+terraform {
+  required_version = "1.11.2"
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = ">= 5.40.0"
+    }
+  }
+}
@@ -0,0 +1,9 @@
+terraform {
+  backend "s3" {
+    allowed_account_ids = ["123456789012"]
+    assume_role = {
+      role_arn = "arn:aws:iam::123456789012:role/state"
+    }
+    bucket = "my-bucket"
+  }
+}
@@ -0,0 +1,12 @@
+module "my-module" {
+  source    = "./my-module"
+  big       = 12345678901234567890
+  directive = "%%{ if true }literal%%{ endif }"
+  mixed = [1, "two", null, {
+    nested = [350]
+  }]
+  quoted   = "say \"hi\"\nand <bye>"
+  ratio    = 0.1
+  template = "$${var.not_a_reference}"
+  unset    = null
+}
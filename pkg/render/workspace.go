@@ -1,9 +1,7 @@
 package render
 
 import (
-	"encoding/json"
 	"fmt"
-	"log"
 
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/zclconf/go-cty/cty"
@@ -29,41 +27,40 @@ func addRequiredProviders(body *hclwrite.Body, providers []tfreconcilev1alpha1.P
 func addBackend(body *hclwrite.Body, backend tfreconcilev1alpha1.BackendSpec) error {
 	be := body.AppendNewBlock("backend", []string{backend.Type})
 	if backend.Inputs != nil {
-		var inputs map[string]interface{}
-		err := json.Unmarshal(backend.Inputs.Raw, &inputs)
+		inputs, err := decodeInputs(backend.Inputs.Raw)
 		if err != nil {
 			return fmt.Errorf("could not unmarshal inputs: %w", err)
 		}
 
 		// Set each key-value pair as an attribute in the backend block
-		for key, value := range inputs {
-			switch v := value.(type) {
-			case string:
-				be.Body().SetAttributeValue(key, cty.StringVal(v))
-			case float64:
-				be.Body().SetAttributeValue(key, cty.NumberFloatVal(v))
-			case bool:
-				be.Body().SetAttributeValue(key, cty.BoolVal(v))
-			default:
-				log.Printf("unsupported type for key %s: %T", key, v)
-			}
+		err = mapInputsToBody(be.Body(), inputs)
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func Workspace(body *hclwrite.Body, ws tfreconcilev1alpha1.Workspace) error {
-	// Create the terraform block
+// Versions renders the terraform block pinning the terraform version and the required providers.
+func Versions(body *hclwrite.Body, ws tfreconcilev1alpha1.Workspace) error {
 	terraformBlock := body.AppendNewBlock("terraform", nil)
-	//terraformBlock.Body().SetAttributeValue("required_version", cty.StringVal(">= 1.7.4"))
+	if ws.Spec.TerraformVersion != "" {
+		terraformBlock.Body().SetAttributeValue("required_version", cty.StringVal(ws.Spec.TerraformVersion))
+	}
 
 	err := addRequiredProviders(terraformBlock.Body(), ws.Spec.ProviderSpecs)
 	if err != nil {
 		return fmt.Errorf("failed to add required providers: %w", err)
 	}
-	// Set the required_version attribute
-	err = addBackend(terraformBlock.Body(), ws.Spec.Backend)
+
+	return nil
+}
+
+// Backend renders the terraform block holding the backend configuration.
+func Backend(body *hclwrite.Body, backend tfreconcilev1alpha1.BackendSpec) error {
+	terraformBlock := body.AppendNewBlock("terraform", nil)
+	err := addBackend(terraformBlock.Body(), backend)
 	if err != nil {
 		return fmt.Errorf("failed to add backend: %w", err)
 	}
@@ -9,10 +9,11 @@ import (
 	"lukaspj.io/kube-tf-reconciler/internal/testutils"
 )
 
-func TestRenderWorkspace_Success(t *testing.T) {
+func TestRenderVersions_Success(t *testing.T) {
 	f := hclwrite.NewEmptyFile()
 	ws := tfreconcilev1alpha1.Workspace{
 		Spec: tfreconcilev1alpha1.WorkspaceSpec{
+			TerraformVersion: "1.11.2",
 			ProviderSpecs: []tfreconcilev1alpha1.ProviderSpec{
 				{
 					Name:    "aws",
@@ -20,28 +21,43 @@ func TestRenderWorkspace_Success(t *testing.T) {
 					Version: ">= 5.40.0",
 				},
 			},
-			Backend: tfreconcilev1alpha1.BackendSpec{
-				Type: "s3",
-				Inputs: testutils.Json(map[string]interface{}{
-					"bucket": "my-bucket",
-				}),
-			},
 		},
 	}
 
 	expectedWs := `terraform {
+  required_version = "1.11.2"
   required_providers {
     aws = {
       source  = "hashicorp/aws"
       version = ">= 5.40.0"
     }
   }
+}
+`
+	err := Versions(f.Body(), ws)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedWs, string(f.Bytes()))
+}
+
+func TestRenderBackend_Success(t *testing.T) {
+	f := hclwrite.NewEmptyFile()
+	backend := tfreconcilev1alpha1.BackendSpec{
+		Type: "s3",
+		Inputs: testutils.Json(map[string]interface{}{
+			"bucket": "my-bucket",
+			"key":    "state.tfstate",
+		}),
+	}
+
+	expectedWs := `terraform {
   backend "s3" {
     bucket = "my-bucket"
+    key    = "state.tfstate"
   }
 }
 `
-	err := Workspace(f.Body(), ws)
+	err := Backend(f.Body(), backend)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedWs, string(f.Bytes()))
@@ -5,12 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"time"
 
 	"github.com/hashicorp/go-version"
-	"github.com/hashicorp/hc-install/product"
-	"github.com/hashicorp/hc-install/releases"
-	"github.com/hashicorp/terraform-exec/tfexec"
 	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
 )
 
@@ -18,6 +14,22 @@ type Exec struct {
 	RootDir       string
 	installDir    string
 	WorkspacesDir string
+	// RunsDir holds the directories of the runs in progress, see EndRun.
+	RunsDir string
+
+	// Installers are the installers of the engine binaries, keyed by engine.
+	Installers map[string]Installer
+	// ProviderMirror is injected into the CLI config of every workspace if set.
+	ProviderMirror *ProviderMirror
+	// PluginCache is the provider plugin cache shared by all workspaces.
+	PluginCache *PluginCache
+	// Storage persists workspace directories across restarts.
+	Storage Storage
+	// NewTerraform creates the Terraform of each run, NewTFExec by default.
+	NewTerraform NewTerraformFunc
+	versions     *versionCache
+	locks        *workspaceLocks
+	runVersions  *runVersions
 }
 
 func New(rootDir string) *Exec {
@@ -34,22 +46,123 @@ func New(rootDir string) *Exec {
 
 	installDir := filepath.Join(rootDir, "installs")
 	workspacesDir := filepath.Join(rootDir, "workspaces")
-	err = os.MkdirAll(installDir, 0755)
-	if err != nil {
-		panic(err)
-	}
-	err = os.MkdirAll(workspacesDir, 0755)
-	if err != nil {
-		panic(err)
+	runsDir := filepath.Join(rootDir, "runs")
+	for _, dir := range []string{installDir, workspacesDir, runsDir} {
+		err = os.MkdirAll(dir, 0755)
+		if err != nil {
+			panic(err)
+		}
 	}
 
 	return &Exec{
 		RootDir:       rootDir,
 		installDir:    installDir,
 		WorkspacesDir: workspacesDir,
+		RunsDir:       runsDir,
+		Installers: map[string]Installer{
+			EngineTerraform: &TerraformInstaller{InstallDir: filepath.Join(installDir, "terraform")},
+			EngineOpenTofu: &TofuInstaller{
+				MirrorURL:  DefaultTofuMirror,
+				IndexURL:   DefaultTofuIndex,
+				InstallDir: filepath.Join(installDir, "tofu"),
+			},
+		},
+		PluginCache:  newPluginCache(filepath.Join(rootDir, "plugin-cache"), workspacesDir, runsDir),
+		Storage:      LocalStorage{},
+		NewTerraform: NewTFExec,
+		versions:     newVersionCache(),
+		locks:        newWorkspaceLocks(),
+		runVersions:  newRunVersions(),
 	}
 }
 
+// SetTofuMirror makes OpenTofu binaries be installed from the given mirror, listing versions from the given index.
+// The checksums of the mirror must be signed with the given key.
+func (e *Exec) SetTofuMirror(mirrorURL, indexURL, armoredPublicKey string) {
+	e.Installers[EngineOpenTofu] = &TofuInstaller{
+		MirrorURL:        mirrorURL,
+		IndexURL:         indexURL,
+		InstallDir:       filepath.Join(e.installDir, "tofu"),
+		ArmoredPublicKey: armoredPublicKey,
+	}
+}
+
+// SetTerraformMirror makes terraform be installed from a mirror of releases.hashicorp.com. The checksums of
+// the mirror must be signed with the given key, or with the HashiCorp release key if it is empty.
+func (e *Exec) SetTerraformMirror(baseURL, armoredPublicKey string) {
+	e.Installers[EngineTerraform] = &TerraformInstaller{
+		InstallDir:       filepath.Join(e.installDir, "terraform"),
+		BaseURL:          baseURL,
+		ArmoredPublicKey: armoredPublicKey,
+	}
+}
+
+// SetTerraformDir makes terraform be used from binaries laid out as <dir>/<version>/terraform.
+func (e *Exec) SetTerraformDir(dir string) {
+	e.Installers[EngineTerraform] = &LocalDirInstaller{
+		Dir:    dir,
+		Binary: "terraform",
+	}
+}
+
+// Init initialises the workspace of tf through the shared plugin cache.
+func (e *Exec) Init(ctx context.Context, tf Terraform, upgrade bool) error {
+	return e.PluginCache.Init(ctx, tf, upgrade)
+}
+
+// initHashFile records the inputs hash .terraform was initialised for.
+const initHashFile = "krec-inputs-hash"
+
+// ForgetInit makes the next InitIfChanged initialise the workspace of tf regardless of its inputs.
+func (e *Exec) ForgetInit(tf Terraform) error {
+	err := os.Remove(filepath.Join(tf.WorkingDir(), ".terraform", initHashFile))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear init inputs: %w", err)
+	}
+
+	return nil
+}
+
+// InitIfChanged initialises the workspace of tf unless .terraform was already initialised for the given
+// inputs hash. It reports whether init ran.
+func (e *Exec) InitIfChanged(ctx context.Context, tf Terraform, hash string, upgrade bool) (bool, error) {
+	hashPath := filepath.Join(tf.WorkingDir(), ".terraform", initHashFile)
+	if current, err := os.ReadFile(hashPath); err == nil && string(current) == hash {
+		return false, nil
+	}
+
+	// A failed init leaves .terraform in an unknown state, so the old hash must not survive it
+	err := e.ForgetInit(tf)
+	if err != nil {
+		return false, err
+	}
+
+	err = e.Init(ctx, tf, upgrade)
+	if err != nil {
+		return true, err
+	}
+
+	err = os.WriteFile(hashPath, []byte(hash), 0644)
+	if err != nil {
+		return true, fmt.Errorf("failed to record init inputs: %w", err)
+	}
+
+	return true, nil
+}
+
+func (e *Exec) installerFor(ws tfreconcilev1alpha1.Workspace) (string, Installer, error) {
+	engine := ws.Spec.Engine
+	if engine == "" {
+		engine = EngineTerraform
+	}
+	installer, ok := e.Installers[engine]
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported engine %q", engine)
+	}
+
+	return engine, installer, nil
+}
+
 func (e *Exec) SetupWorkspace(ws string) (string, error) {
 	fullPath := filepath.Join(e.WorkspacesDir, ws)
 	err := os.MkdirAll(fullPath, 0755)
@@ -77,37 +190,80 @@ func (e *Exec) SetupTerraformRC(workspacePath string, terraformRCContent string)
 	return terraformRCPath, nil
 }
 
-func (e *Exec) GetTerraformForWorkspace(ctx context.Context, ws tfreconcilev1alpha1.Workspace) (*tfexec.Terraform, string, error) {
-	path, err := e.SetupWorkspace(filepath.Join(ws.Namespace, ws.Name))
+// GetTerraformForWorkspace prepares a directory for a new run of the workspace and installs the engine of
+// the workspace in the given version, which is normally the result of ResolveVersion. The workspace must be
+// locked with LockWorkspace, and the run ended with EndRun. terraformRC is the CLI configuration of the run,
+// to which that of the provider mirror is appended.
+func (e *Exec) GetTerraformForWorkspace(ctx context.Context, ws tfreconcilev1alpha1.Workspace, v *version.Version, terraformRC string) (Terraform, string, error) {
+	// Directories are restored lazily, the first time a workspace is reconciled after a restart
+	key := workspaceKey(ws)
+	err := e.Storage.Restore(ctx, key, filepath.Join(e.WorkspacesDir, key))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to restore workspace: %w", err)
+	}
+
+	_, err = e.SetupWorkspace(key)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to setup workspace: %w", err)
 	}
+	path, err := e.newRunDir(key)
+	if err != nil {
+		return nil, "", err
+	}
+	// Runs that never got to start are discarded, so they are not mistaken for runs that crashed
+	discard := func(err error) (Terraform, string, error) {
+		_ = e.EndRun(ws, path, false)
+		return nil, "", err
+	}
+
+	if e.ProviderMirror != nil {
+		terraformRC += string(e.ProviderMirror.CLIConfig())
+	}
 
 	var terraformRCPath string
-	if ws.Spec.TerraformRC != "" {
-		terraformRCPath, err = e.SetupTerraformRC(path, ws.Spec.TerraformRC)
+	if terraformRC != "" {
+		terraformRCPath, err = e.SetupTerraformRC(path, terraformRC)
 		if err != nil {
-			return nil, "", fmt.Errorf("failed to setup .terraformrc: %w", err)
+			return discard(fmt.Errorf("failed to setup .terraformrc: %w", err))
 		}
 	}
 
-	installer := &releases.ExactVersion{
-		Product:    product.Terraform,
-		InstallDir: e.installDir,
-		Version:    version.Must(version.NewVersion(ws.Spec.TerraformVersion)),
+	engine, installer, err := e.installerFor(ws)
+	if err != nil {
+		return discard(err)
 	}
 
-	//custom timeout because Openshift is slow
-	installer.Timeout = 2 * time.Minute
-
-	execPath, err := installer.Install(ctx)
+	// The version is in use from before it is installed until the run ends
+	e.runVersions.add(path, engine+"/"+v.String())
+	execPath, err := installer.Install(ctx, v)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to install terraform: %w", err)
+		return discard(fmt.Errorf("failed to install %s: %w", engine, err))
 	}
-	tf, err := tfexec.NewTerraform(path, execPath)
+	tf, err := e.NewTerraform(path, execPath)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to create terraform instance: %w", err)
+		return discard(fmt.Errorf("failed to create terraform instance: %w", err))
 	}
 
 	return tf, terraformRCPath, nil
 }
+
+func workspaceKey(ws tfreconcilev1alpha1.Workspace) string {
+	return filepath.Join(ws.Namespace, ws.Name)
+}
+
+// SaveWorkspace persists the directory of the workspace to the storage.
+func (e *Exec) SaveWorkspace(ctx context.Context, ws tfreconcilev1alpha1.Workspace) error {
+	key := workspaceKey(ws)
+	return e.Storage.Save(ctx, key, filepath.Join(e.WorkspacesDir, key))
+}
+
+// DeleteWorkspace removes the directory of the workspace, both locally and from the storage.
+func (e *Exec) DeleteWorkspace(ctx context.Context, ws tfreconcilev1alpha1.Workspace) error {
+	key := workspaceKey(ws)
+	err := os.RemoveAll(filepath.Join(e.WorkspacesDir, key))
+	if err != nil {
+		return fmt.Errorf("failed to remove workspace dir: %w", err)
+	}
+
+	return e.Storage.Delete(ctx, key)
+}
@@ -1 +1,22 @@
 package runner
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+func newTestWorkspace() tfreconcilev1alpha1.Workspace {
+	return tfreconcilev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-workspace",
+			Namespace: "default",
+		},
+		Spec: tfreconcilev1alpha1.WorkspaceSpec{
+			TerraformVersion: "1.11.2",
+			Module: &tfreconcilev1alpha1.ModuleSpec{
+				Name:   "my-module",
+				Source: "./my-module",
+			},
+		},
+	}
+}
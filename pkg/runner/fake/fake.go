@@ -0,0 +1,252 @@
+// Package fake has implementations of the runner interfaces that run and download nothing, so the controller
+// can be tested through whole workspace lifecycles offline.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+	"lukaspj.io/kube-tf-reconciler/pkg/runner"
+)
+
+// Terraform scripts the outcome of the commands of every run created with New, and records the commands
+// the runs were asked to run.
+//
+// Like terraform, init creates .terraform, plan writes the plan file, apply writes terraform.tfstate and
+// destroy removes it, so the run directories look like real ones.
+type Terraform struct {
+	// Changes is whether plans have changes. A plan with changes creates one resource.
+	Changes bool
+	// Outputs are the outputs returned once applied.
+	Outputs map[string]tfexec.OutputMeta
+	// Diagnostics are returned by validate, an error diagnostic makes the configuration invalid.
+	Diagnostics []tfjson.Diagnostic
+	// Errors make the commands with the given name fail, see Call.Command for the names.
+	Errors map[string]error
+	// Block makes the commands with the given name block until their context is done, like terraform
+	// running for a long time.
+	Block map[string]bool
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Call is a command a run was asked to run.
+type Call struct {
+	// Command is the name of the command, like init, plan or state push.
+	Command    string
+	WorkingDir string
+	Env        map[string]string
+}
+
+// New creates the Terraform of a run in workingDir. It is a runner.NewTerraformFunc, the binary at execPath
+// is never run.
+func (f *Terraform) New(workingDir, execPath string) (runner.Terraform, error) {
+	return &run{f: f, workingDir: workingDir}, nil
+}
+
+// Calls returns the commands run so far, in order.
+func (f *Terraform) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// Commands returns the names of the commands run so far, in order.
+func (f *Terraform) Commands() []string {
+	var commands []string
+	for _, call := range f.Calls() {
+		commands = append(commands, call.Command)
+	}
+	return commands
+}
+
+// Reset forgets the commands run so far.
+func (f *Terraform) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+}
+
+func (f *Terraform) call(ctx context.Context, r *run, command string) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{Command: command, WorkingDir: r.workingDir, Env: r.env})
+	block := f.Block[command]
+	err := f.Errors[command]
+	f.mu.Unlock()
+
+	if block {
+		<-ctx.Done()
+		return fmt.Errorf("terraform %s interrupted: %w", command, ctx.Err())
+	}
+	return err
+}
+
+type run struct {
+	f          *Terraform
+	workingDir string
+	env        map[string]string
+}
+
+func (r *run) WorkingDir() string {
+	return r.workingDir
+}
+
+func (r *run) SetEnv(env map[string]string) error {
+	r.env = env
+	return nil
+}
+
+func (r *run) Init(ctx context.Context, upgrade bool) error {
+	err := r.f.call(ctx, r, "init")
+	if err != nil {
+		return err
+	}
+
+	return os.MkdirAll(filepath.Join(r.workingDir, ".terraform"), 0755)
+}
+
+func (r *run) Validate(ctx context.Context) (*tfjson.ValidateOutput, error) {
+	err := r.f.call(ctx, r, "validate")
+	if err != nil {
+		return nil, err
+	}
+
+	out := &tfjson.ValidateOutput{FormatVersion: "1.0", Valid: true}
+	for _, d := range r.f.Diagnostics {
+		out.Diagnostics = append(out.Diagnostics, d)
+		if d.Severity == tfjson.DiagnosticSeverityError {
+			out.Valid = false
+			out.ErrorCount++
+		} else {
+			out.WarningCount++
+		}
+	}
+	return out, nil
+}
+
+func (r *run) Plan(ctx context.Context, planFile string) (bool, error) {
+	err := r.f.call(ctx, r, "plan")
+	if err != nil {
+		return false, err
+	}
+
+	content := "no changes"
+	if r.f.Changes {
+		content = "changes"
+	}
+	err = os.WriteFile(filepath.Join(r.workingDir, planFile), []byte(content), 0644)
+	if err != nil {
+		return false, err
+	}
+
+	return r.f.Changes, nil
+}
+
+// planHasChanges reads back what Plan wrote, so showing a plan doesn't depend on Changes having stayed
+// the same.
+func (r *run) planHasChanges(planFile string) (bool, error) {
+	content, err := os.ReadFile(filepath.Join(r.workingDir, planFile))
+	if err != nil {
+		return false, err
+	}
+	return string(content) == "changes", nil
+}
+
+func (r *run) ShowPlanFile(ctx context.Context, planFile string) (*tfjson.Plan, error) {
+	err := r.f.call(ctx, r, "show")
+	if err != nil {
+		return nil, err
+	}
+	changes, err := r.planHasChanges(planFile)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &tfjson.Plan{FormatVersion: "1.2"}
+	if changes {
+		plan.ResourceChanges = []*tfjson.ResourceChange{{
+			Address: "terraform_data.fake",
+			Type:    "terraform_data",
+			Name:    "fake",
+			Change:  &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionCreate}},
+		}}
+	}
+	return plan, nil
+}
+
+func (r *run) ShowPlanFileRaw(ctx context.Context, planFile string) (string, error) {
+	err := r.f.call(ctx, r, "show")
+	if err != nil {
+		return "", err
+	}
+	changes, err := r.planHasChanges(planFile)
+	if err != nil {
+		return "", err
+	}
+
+	if changes {
+		return "Plan: 1 to add, 0 to change, 0 to destroy.", nil
+	}
+	return "No changes. Your infrastructure matches the configuration.", nil
+}
+
+func (r *run) Apply(ctx context.Context) error {
+	err := r.f.call(ctx, r, "apply")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(r.workingDir, "terraform.tfstate"), []byte(`{"version": 4}`), 0644)
+}
+
+func (r *run) Destroy(ctx context.Context) error {
+	err := r.f.call(ctx, r, "destroy")
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filepath.Join(r.workingDir, "terraform.tfstate"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (r *run) Output(ctx context.Context) (map[string]tfexec.OutputMeta, error) {
+	err := r.f.call(ctx, r, "output")
+	if err != nil {
+		return nil, err
+	}
+
+	return r.f.Outputs, nil
+}
+
+func (r *run) StatePush(ctx context.Context, path string) error {
+	return r.f.call(ctx, r, "state push")
+}
+
+func (r *run) ForceUnlock(ctx context.Context, lockID string) error {
+	return r.f.call(ctx, r, "force-unlock")
+}
+
+// Installer is a runner.Installer that installs nothing, for use with Terraform. Any version installs, to a
+// path that doesn't exist.
+type Installer struct {
+	// Available are the versions listed, for workspaces with a version constraint.
+	Available []*version.Version
+}
+
+func (i *Installer) Install(ctx context.Context, v *version.Version) (string, error) {
+	return filepath.Join("/fake", v.String(), "terraform"), nil
+}
+
+func (i *Installer) Versions(ctx context.Context) ([]*version.Version, error) {
+	return i.Available, nil
+}
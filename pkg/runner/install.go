@@ -0,0 +1,397 @@
+package runner
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hc-install/product"
+	"github.com/hashicorp/hc-install/releases"
+)
+
+const (
+	// EngineTerraform runs workspaces with HashiCorp terraform.
+	EngineTerraform = "terraform"
+	// EngineOpenTofu runs workspaces with OpenTofu.
+	EngineOpenTofu = "opentofu"
+
+	// DefaultTofuMirror is where OpenTofu publishes its release binaries.
+	DefaultTofuMirror = "https://github.com/opentofu/opentofu/releases/download"
+	// DefaultTofuIndex lists the published OpenTofu versions.
+	DefaultTofuIndex = "https://get.opentofu.org/tofu/api.json"
+)
+
+// Installer installs the binary of an engine in a given version and returns the path to it.
+type Installer interface {
+	Install(ctx context.Context, version *version.Version) (string, error)
+	// Versions lists the versions available for installation.
+	Versions(ctx context.Context) ([]*version.Version, error)
+}
+
+// TerraformInstaller installs terraform from releases.hashicorp.com, or from a mirror of it.
+// Each version is installed into its own directory and reused once installed.
+type TerraformInstaller struct {
+	InstallDir string
+	// BaseURL is the base URL of a mirror with the same layout as releases.hashicorp.com, including
+	// the index.json files. Empty means releases.hashicorp.com.
+	BaseURL string
+	// ArmoredPublicKey is the PGP key the checksums of the mirror are signed with. Empty means the
+	// HashiCorp release key.
+	ArmoredPublicKey string
+	// Client is used to list the versions available on the mirror.
+	Client *http.Client
+}
+
+func (i *TerraformInstaller) Install(ctx context.Context, v *version.Version) (string, error) {
+	versionDir := filepath.Join(i.InstallDir, v.String())
+	binPath := filepath.Join(versionDir, product.Terraform.BinaryName())
+	if _, err := os.Stat(binPath); err == nil {
+		return binPath, nil
+	}
+
+	err := os.MkdirAll(i.InstallDir, 0755)
+	if err != nil {
+		return "", err
+	}
+	tmpDir, err := os.MkdirTemp(i.InstallDir, ".install-"+v.String()+"-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	installer := &releases.ExactVersion{
+		Product:          product.Terraform,
+		InstallDir:       tmpDir,
+		Version:          v,
+		ApiBaseURL:       i.BaseURL,
+		ArmoredPublicKey: i.ArmoredPublicKey,
+	}
+
+	//custom timeout because Openshift is slow
+	installer.Timeout = 2 * time.Minute
+
+	_, err = installer.Install(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Another reconcile may have installed the same version in the meantime, which is just as good
+	err = os.Rename(tmpDir, versionDir)
+	if err != nil {
+		if _, statErr := os.Stat(binPath); statErr != nil {
+			return "", fmt.Errorf("failed to move terraform into place: %w", err)
+		}
+	}
+
+	return binPath, nil
+}
+
+type releasesIndex struct {
+	Versions map[string]json.RawMessage `json:"versions"`
+}
+
+func (i *TerraformInstaller) Versions(ctx context.Context) ([]*version.Version, error) {
+	if i.BaseURL == "" {
+		lister := &releases.Versions{Product: product.Terraform}
+		sources, err := lister.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var versions []*version.Version
+		for _, s := range sources {
+			if ev, ok := s.(*releases.ExactVersion); ok {
+				versions = append(versions, ev.Version)
+			}
+		}
+
+		return versions, nil
+	}
+
+	indexURL := fmt.Sprintf("%s/%s/index.json", strings.TrimSuffix(i.BaseURL, "/"), product.Terraform.Name)
+	content, err := download(ctx, httpClient(i.Client), indexURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download version index: %w", err)
+	}
+
+	var index releasesIndex
+	err = json.Unmarshal(content, &index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse version index: %w", err)
+	}
+
+	var versions []*version.Version
+	for raw := range index.Versions {
+		v, err := version.NewVersion(raw)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+
+	return versions, nil
+}
+
+// LocalDirInstaller uses binaries that are already present in a local directory, laid out as
+// <dir>/<version>/<binary>. It never downloads anything, which suits air-gapped clusters where the
+// binaries are baked into the image or mounted from a volume.
+type LocalDirInstaller struct {
+	Dir    string
+	Binary string
+}
+
+func (i *LocalDirInstaller) Install(ctx context.Context, v *version.Version) (string, error) {
+	binPath := filepath.Join(i.Dir, v.String(), i.Binary)
+	if _, err := os.Stat(binPath); err != nil {
+		return "", fmt.Errorf("%s %s is not available in %s: %w", i.Binary, v, i.Dir, err)
+	}
+
+	return binPath, nil
+}
+
+func (i *LocalDirInstaller) Versions(ctx context.Context) ([]*version.Version, error) {
+	entries, err := os.ReadDir(i.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", i.Dir, err)
+	}
+
+	var versions []*version.Version
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		v, err := version.NewVersion(entry.Name())
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(i.Dir, entry.Name(), i.Binary)); err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+
+	return versions, nil
+}
+
+// TofuInstaller installs tofu from a mirror laid out like the OpenTofu GitHub releases, i.e.
+// <mirror>/v<version>/tofu_<version>_<os>_<arch>.zip next to tofu_<version>_SHA256SUMS and its detached
+// signature tofu_<version>_SHA256SUMS.gpgsig. The signature is verified with ArmoredPublicKey and the archive
+// against the checksums before it is extracted.
+// The available versions are read from an index shaped like https://get.opentofu.org/tofu/api.json.
+type TofuInstaller struct {
+	MirrorURL  string
+	IndexURL   string
+	InstallDir string
+	// ArmoredPublicKey is the PGP key the checksums of the mirror are signed with, such as the OpenTofu
+	// release key. Nothing is installed without it.
+	ArmoredPublicKey string
+	Client           *http.Client
+}
+
+func (i *TofuInstaller) Install(ctx context.Context, tofuVersion *version.Version) (string, error) {
+	v := tofuVersion.String()
+
+	binPath := filepath.Join(i.InstallDir, v, "tofu")
+	if _, err := os.Stat(binPath); err == nil {
+		return binPath, nil
+	}
+
+	if i.ArmoredPublicKey == "" {
+		return "", fmt.Errorf("no public key configured to verify tofu %s with", v)
+	}
+
+	base := fmt.Sprintf("%s/v%s", strings.TrimSuffix(i.MirrorURL, "/"), v)
+	archiveName := fmt.Sprintf("tofu_%s_%s_%s.zip", v, runtime.GOOS, runtime.GOARCH)
+	sumsURL := fmt.Sprintf("%s/tofu_%s_SHA256SUMS", base, v)
+
+	sums, err := download(ctx, i.client(), sumsURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to download checksums: %w", err)
+	}
+	sig, err := download(ctx, i.client(), sumsURL+".gpgsig")
+	if err != nil {
+		return "", fmt.Errorf("failed to download checksums signature: %w", err)
+	}
+	err = verifySignature(i.ArmoredPublicKey, sums, sig)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify checksums of tofu %s: %w", v, err)
+	}
+
+	archive, err := download(ctx, i.client(), fmt.Sprintf("%s/%s", base, archiveName))
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", archiveName, err)
+	}
+
+	err = verifyChecksum(sums, archiveName, archive)
+	if err != nil {
+		return "", err
+	}
+
+	err = extractBinary(archive, "tofu", binPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract tofu: %w", err)
+	}
+
+	return binPath, nil
+}
+
+type tofuIndex struct {
+	Versions []struct {
+		ID string `json:"id"`
+	} `json:"versions"`
+}
+
+func (i *TofuInstaller) Versions(ctx context.Context) ([]*version.Version, error) {
+	content, err := download(ctx, i.client(), i.IndexURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download version index: %w", err)
+	}
+
+	var index tofuIndex
+	err = json.Unmarshal(content, &index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse version index: %w", err)
+	}
+
+	var versions []*version.Version
+	for _, entry := range index.Versions {
+		v, err := version.NewVersion(entry.ID)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+
+	return versions, nil
+}
+
+func (i *TofuInstaller) client() *http.Client {
+	return httpClient(i.Client)
+}
+
+func httpClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return &http.Client{Timeout: 2 * time.Minute}
+}
+
+func download(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s from %s", resp.Status, url)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// verifySignature checks a detached PGP signature of content, binary or armored, against an armored public key.
+func verifySignature(armoredPublicKey string, content, sig []byte) error {
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredPublicKey))
+	if err != nil {
+		return fmt.Errorf("failed to read public key: %w", err)
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(sig), []byte("-----BEGIN")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(content), bytes.NewReader(sig), nil)
+	} else {
+		_, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(content), bytes.NewReader(sig), nil)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+
+	return nil
+}
+
+// verifyChecksum checks content against the entry for name in a SHA256SUMS file.
+func verifyChecksum(sums []byte, name string, content []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != strings.ToLower(fields[0]) {
+			return fmt.Errorf("checksum mismatch for %s", name)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("no checksum found for %s", name)
+}
+
+// extractBinary writes the named file of a zip archive to dest. The file is written next to dest first
+// and renamed into place, so concurrent installs of the same version never see a partial binary.
+func extractBinary(archive []byte, name, dest string) error {
+	r, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	for _, f := range r.File {
+		if f.Name != name {
+			continue
+		}
+
+		err = os.MkdirAll(filepath.Dir(dest), 0755)
+		if err != nil {
+			return err
+		}
+
+		src, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		tmp, err := os.CreateTemp(filepath.Dir(dest), name+"-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+
+		_, err = io.Copy(tmp, src)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+
+		err = os.Chmod(tmp.Name(), 0755)
+		if err != nil {
+			return err
+		}
+
+		return os.Rename(tmp.Name(), dest)
+	}
+
+	return fmt.Errorf("%s not found in archive", name)
+}
@@ -0,0 +1,153 @@
+package runner
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeTofu = "#!/bin/sh\necho fake tofu\n"
+
+func zipBinary(t *testing.T, name, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create(name)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+// tofuMirror serves a single tofu release laid out like the OpenTofu GitHub releases, with the checksums
+// signed by signer.
+func tofuMirror(t *testing.T, v string, archive []byte, sum string, signer *openpgp.Entity) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	archiveName := fmt.Sprintf("tofu_%s_%s_%s.zip", v, runtime.GOOS, runtime.GOARCH)
+	sums := fmt.Sprintf("0000000000000000000000000000000000000000000000000000000000000000  tofu_%s_other_arch.zip\n", v) +
+		fmt.Sprintf("%s  %s\n", sum, archiveName)
+	var sig bytes.Buffer
+	require.NoError(t, openpgp.DetachSign(&sig, signer, strings.NewReader(sums), nil))
+	var downloads atomic.Int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(fmt.Sprintf("/v%s/tofu_%s_SHA256SUMS", v, v), func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(sums))
+	})
+	mux.HandleFunc(fmt.Sprintf("/v%s/tofu_%s_SHA256SUMS.gpgsig", v, v), func(w http.ResponseWriter, r *http.Request) {
+		w.Write(sig.Bytes())
+	})
+	mux.HandleFunc(fmt.Sprintf("/v%s/%s", v, archiveName), func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		w.Write(archive)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, &downloads
+}
+
+func TestTofuInstaller(t *testing.T) {
+	signer, publicKey := newSigningKey(t)
+	archive := zipBinary(t, "tofu", fakeTofu)
+	sum := sha256.Sum256(archive)
+	srv, downloads := tofuMirror(t, "1.9.0", archive, hex.EncodeToString(sum[:]), signer)
+
+	installer := &TofuInstaller{MirrorURL: srv.URL, InstallDir: t.TempDir(), ArmoredPublicKey: publicKey}
+	path, err := installer.Install(context.Background(), version.Must(version.NewVersion("1.9.0")))
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, fakeTofu, string(content))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&0100, "binary should be executable")
+
+	again, err := installer.Install(context.Background(), version.Must(version.NewVersion("v1.9.0")))
+	require.NoError(t, err)
+	assert.Equal(t, path, again)
+	assert.Equal(t, int32(1), downloads.Load(), "installed binaries should be reused")
+}
+
+func TestTofuInstallerChecksumMismatch(t *testing.T) {
+	signer, publicKey := newSigningKey(t)
+	archive := zipBinary(t, "tofu", fakeTofu)
+	srv, _ := tofuMirror(t, "1.9.0", archive, hex.EncodeToString(make([]byte, sha256.Size)), signer)
+
+	dir := t.TempDir()
+	installer := &TofuInstaller{MirrorURL: srv.URL, InstallDir: dir, ArmoredPublicKey: publicKey}
+	_, err := installer.Install(context.Background(), version.Must(version.NewVersion("1.9.0")))
+	assert.ErrorContains(t, err, "checksum mismatch")
+
+	_, err = os.Stat(filepath.Join(dir, "1.9.0", "tofu"))
+	assert.True(t, os.IsNotExist(err), "unverified binary must not be installed")
+}
+
+func TestTofuInstallerUntrustedSignature(t *testing.T) {
+	signer, _ := newSigningKey(t)
+	_, trusted := newSigningKey(t)
+	archive := zipBinary(t, "tofu", fakeTofu)
+	sum := sha256.Sum256(archive)
+	srv, downloads := tofuMirror(t, "1.9.0", archive, hex.EncodeToString(sum[:]), signer)
+
+	dir := t.TempDir()
+	installer := &TofuInstaller{MirrorURL: srv.URL, InstallDir: dir, ArmoredPublicKey: trusted}
+	_, err := installer.Install(context.Background(), version.Must(version.NewVersion("1.9.0")))
+	assert.ErrorContains(t, err, "invalid signature")
+	assert.Zero(t, downloads.Load(), "the archive is only downloaded once the checksums are trusted")
+
+	installer.ArmoredPublicKey = ""
+	_, err = installer.Install(context.Background(), version.Must(version.NewVersion("1.9.0")))
+	assert.ErrorContains(t, err, "no public key")
+
+	_, err = os.Stat(filepath.Join(dir, "1.9.0", "tofu"))
+	assert.True(t, os.IsNotExist(err), "unverified binary must not be installed")
+}
+
+func TestTofuInstallerMissingVersion(t *testing.T) {
+	signer, publicKey := newSigningKey(t)
+	srv, _ := tofuMirror(t, "1.9.0", nil, "", signer)
+
+	installer := &TofuInstaller{MirrorURL: srv.URL, InstallDir: t.TempDir(), ArmoredPublicKey: publicKey}
+	_, err := installer.Install(context.Background(), version.Must(version.NewVersion("1.8.0")))
+	assert.Error(t, err)
+}
+
+func TestGetTerraformForWorkspaceUsesEngineInstaller(t *testing.T) {
+	signer, publicKey := newSigningKey(t)
+	archive := zipBinary(t, "tofu", fakeTofu)
+	sum := sha256.Sum256(archive)
+	srv, _ := tofuMirror(t, "1.9.0", archive, hex.EncodeToString(sum[:]), signer)
+
+	e := New(t.TempDir())
+	e.SetTofuMirror(srv.URL, srv.URL+"/api.json", publicKey)
+
+	ws := newTestWorkspace()
+	ws.Spec.Engine = EngineOpenTofu
+	ws.Spec.TerraformVersion = "1.9.0"
+	tf, _, err := e.GetTerraformForWorkspace(context.Background(), ws, version.Must(version.NewVersion("1.9.0")), "")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(e.RunsDir, ws.Namespace, ws.Name), filepath.Dir(tf.WorkingDir()))
+
+	ws.Spec.Engine = "pulumi"
+	_, _, err = e.GetTerraformForWorkspace(context.Background(), ws, version.Must(version.NewVersion("1.9.0")), "")
+	assert.ErrorContains(t, err, "unsupported engine")
+}
@@ -0,0 +1,165 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// InUse describes what the workspaces that exist use. CollectGarbage may remove anything else.
+type InUse struct {
+	// Workspaces are the keys of the workspaces, of the form <namespace>/<name>.
+	Workspaces map[string]bool
+	// Versions are the engine versions the workspaces resolved to, of the form <engine>/<version>.
+	Versions map[string]bool
+}
+
+// CollectGarbage removes what is not in use and was last modified before cutoff: the workspace and run
+// directories of deleted workspaces, installs of engine versions neither a workspace resolves to anymore nor
+// a run in progress uses, and what interrupted snapshots, restores and installs left behind. Errors are
+// collected and returned together, so one entry that can't be removed doesn't keep the others around.
+func (e *Exec) CollectGarbage(inUse InUse, cutoff time.Time) error {
+	var errs []error
+
+	// workspaces/<namespace>/<name>, next to leftovers of snapshots and restores
+	errs = append(errs, removeStale(filepath.Join(e.WorkspacesDir, "*", "*"), cutoff, func(path string) bool {
+		name := filepath.Base(path)
+		if strings.HasPrefix(name, ".snapshot-") || strings.HasPrefix(name, ".restore-") {
+			return true
+		}
+		return !strings.HasPrefix(name, ".") && !inUse.Workspaces[relKey(e.WorkspacesDir, path)]
+	}))
+
+	// runs/<namespace>/<name>, runs of existing workspaces are left to LockWorkspace
+	errs = append(errs, removeStale(filepath.Join(e.RunsDir, "*", "*"), cutoff, func(path string) bool {
+		return !inUse.Workspaces[relKey(e.RunsDir, path)]
+	}))
+
+	running, unlock := e.runVersions.lockUsed()
+	defer unlock()
+	for engine, installer := range e.Installers {
+		var installDir string
+		switch i := installer.(type) {
+		case *TerraformInstaller:
+			installDir = i.InstallDir
+		case *TofuInstaller:
+			installDir = i.InstallDir
+		default:
+			// Binaries the operator did not install are not its to remove
+			continue
+		}
+
+		errs = append(errs, removeStale(filepath.Join(installDir, "*"), cutoff, func(path string) bool {
+			name := filepath.Base(path)
+			if strings.HasPrefix(name, ".install-") {
+				return true
+			}
+			version := engine + "/" + name
+			return !strings.HasPrefix(name, ".") && !inUse.Versions[version] && !running[version]
+		}))
+	}
+
+	return errors.Join(errs...)
+}
+
+// CollectSnapshots removes the snapshots of deleted workspaces from the storage, if they were last saved
+// before cutoff.
+func (e *Exec) CollectSnapshots(ctx context.Context, inUse InUse, cutoff time.Time) error {
+	snapshots, err := e.Storage.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for key, modTime := range snapshots {
+		if inUse.Workspaces[key] || !modTime.Before(cutoff) {
+			continue
+		}
+
+		err = e.Storage.Delete(ctx, key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		janitorRemovals.Inc()
+	}
+
+	return errors.Join(errs...)
+}
+
+// relKey returns the key of the workspace or run directory path under root.
+func relKey(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// RemoveStaleFiles removes the files in dir matching any of the glob patterns that were last modified before
+// cutoff.
+func RemoveStaleFiles(dir string, patterns []string, cutoff time.Time) error {
+	var errs []error
+	for _, pattern := range patterns {
+		errs = append(errs, removeStale(filepath.Join(dir, pattern), cutoff, func(string) bool { return true }))
+	}
+	return errors.Join(errs...)
+}
+
+// removeStale removes the paths matching the glob pattern that were last modified before cutoff and that
+// remove accepts.
+func removeStale(pattern string, cutoff time.Time, remove func(path string) bool) error {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, path := range matches {
+		info, err := os.Lstat(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if !info.ModTime().Before(cutoff) || !remove(path) {
+			continue
+		}
+
+		err = os.RemoveAll(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove %s: %w", path, err))
+			continue
+		}
+		janitorRemovals.Inc()
+	}
+
+	return errors.Join(errs...)
+}
+
+// ReportDiskUsage updates the disk usage metric of each directory the operator keeps data in.
+func (e *Exec) ReportDiskUsage() error {
+	dirs := map[string]string{
+		"workspaces":   e.WorkspacesDir,
+		"runs":         e.RunsDir,
+		"installs":     e.installDir,
+		"plugin-cache": e.PluginCache.Dir,
+	}
+
+	var errs []error
+	for name, dir := range dirs {
+		size, err := dirSize(dir)
+		if err != nil && !os.IsNotExist(err) {
+			errs = append(errs, fmt.Errorf("failed to get size of %s: %w", dir, err))
+			continue
+		}
+		diskUsage.WithLabelValues(name).Set(float64(size))
+	}
+
+	return errors.Join(errs...)
+}
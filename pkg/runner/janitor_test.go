@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// age makes path look last modified the given time ago.
+func age(t *testing.T, path string, ago time.Duration) {
+	t.Helper()
+	modTime := time.Now().Add(-ago)
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestCollectGarbage(t *testing.T) {
+	e := New(t.TempDir())
+	terraformDir := e.Installers[EngineTerraform].(*TerraformInstaller).InstallDir
+	paths := map[string]string{
+		"live workspace":    filepath.Join(e.WorkspacesDir, "default", "live"),
+		"deleted workspace": filepath.Join(e.WorkspacesDir, "default", "deleted"),
+		"new workspace":     filepath.Join(e.WorkspacesDir, "default", "new"),
+		"snapshot":          filepath.Join(e.WorkspacesDir, "default", ".snapshot-live-1.tar.gz"),
+		"restore":           filepath.Join(e.WorkspacesDir, "default", ".restore-live-1"),
+		"live run":          filepath.Join(e.RunsDir, "default", "live"),
+		"deleted run":       filepath.Join(e.RunsDir, "default", "deleted"),
+		"used install":      filepath.Join(terraformDir, "1.11.2"),
+		"unused install":    filepath.Join(terraformDir, "1.9.0"),
+		"partial install":   filepath.Join(terraformDir, ".install-1.10.0-1"),
+	}
+	for name, path := range paths {
+		require.NoError(t, os.MkdirAll(path, 0755))
+		if name != "new workspace" {
+			age(t, path, 2*time.Hour)
+		}
+	}
+
+	err := e.CollectGarbage(InUse{
+		Workspaces: map[string]bool{"default/live": true},
+		Versions:   map[string]bool{"terraform/1.11.2": true},
+	}, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+
+	for _, name := range []string{"live workspace", "new workspace", "live run", "used install"} {
+		assert.DirExists(t, paths[name], name)
+	}
+	for _, name := range []string{"deleted workspace", "snapshot", "restore", "deleted run", "unused install", "partial install"} {
+		assert.NoDirExists(t, paths[name], name)
+	}
+}
+
+func TestCollectGarbageKeepsVersionsOfRuns(t *testing.T) {
+	e := New(t.TempDir())
+	install := filepath.Join(e.Installers[EngineTerraform].(*TerraformInstaller).InstallDir, "1.9.0")
+	require.NoError(t, os.MkdirAll(install, 0755))
+	age(t, install, 2*time.Hour)
+
+	// A run resolved a version no workspace status records yet
+	run := filepath.Join(e.RunsDir, "default", "ws", "run-1")
+	require.NoError(t, os.MkdirAll(run, 0755))
+	e.runVersions.add(run, "terraform/1.9.0")
+	inUse := InUse{Workspaces: map[string]bool{"default/ws": true}, Versions: map[string]bool{}}
+	require.NoError(t, e.CollectGarbage(inUse, time.Now().Add(-time.Hour)))
+	assert.DirExists(t, install)
+
+	require.NoError(t, e.EndRun(newTestWorkspace(), run, false))
+	require.NoError(t, e.CollectGarbage(inUse, time.Now().Add(-time.Hour)))
+	assert.NoDirExists(t, install)
+}
+
+func TestRemoveStaleFiles(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "aws-token-default-ws-1")
+	fresh := filepath.Join(dir, "aws-token-default-ws-2")
+	other := filepath.Join(dir, "other")
+	for _, path := range []string{stale, fresh, other} {
+		require.NoError(t, os.WriteFile(path, []byte("token"), 0600))
+	}
+	age(t, stale, 2*time.Hour)
+	age(t, other, 2*time.Hour)
+
+	require.NoError(t, RemoveStaleFiles(dir, []string{"aws-token-*"}, time.Now().Add(-time.Hour)))
+	assert.NoFileExists(t, stale)
+	assert.FileExists(t, fresh)
+	assert.FileExists(t, other)
+}
+
+func TestReportDiskUsage(t *testing.T) {
+	e := New(t.TempDir())
+	require.NoError(t, os.MkdirAll(filepath.Join(e.WorkspacesDir, "default", "ws"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(e.WorkspacesDir, "default", "ws", "terraform.tfstate"), make([]byte, 100), 0644))
+
+	require.NoError(t, e.ReportDiskUsage())
+	assert.Equal(t, float64(100), testutil.ToFloat64(diskUsage.WithLabelValues("workspaces")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(diskUsage.WithLabelValues("plugin-cache")))
+}
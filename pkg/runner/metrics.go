@@ -0,0 +1,37 @@
+package runner
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	pluginCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "krec_plugin_cache_hits_total",
+		Help: "Number of providers installed into a workspace from the shared plugin cache.",
+	})
+	pluginCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "krec_plugin_cache_misses_total",
+		Help: "Number of providers that had to be downloaded because they were not in the shared plugin cache.",
+	})
+	pluginCacheEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "krec_plugin_cache_evictions_total",
+		Help: "Number of provider versions evicted from the shared plugin cache.",
+	})
+	pluginCacheSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "krec_plugin_cache_size_bytes",
+		Help: "Size of the shared plugin cache after the last init.",
+	})
+	janitorRemovals = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "krec_janitor_removals_total",
+		Help: "Number of directories and files removed by the janitor because nothing uses them anymore.",
+	})
+	diskUsage = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "krec_disk_usage_bytes",
+		Help: "Disk space used by each directory the operator keeps data in, as of the last janitor run.",
+	}, []string{"dir"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(pluginCacheHits, pluginCacheMisses, pluginCacheEvictions, pluginCacheSize, janitorRemovals, diskUsage)
+}
@@ -0,0 +1,37 @@
+package runner
+
+import (
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ProviderMirror makes terraform install providers from a mirror instead of their origin registries.
+type ProviderMirror struct {
+	// NetworkURL is the URL of a provider network mirror, see
+	// https://developer.hashicorp.com/terraform/internals/provider-network-mirror-protocol
+	NetworkURL string
+	// FilesystemPath is the path of a local directory laid out as a provider filesystem mirror.
+	FilesystemPath string
+}
+
+// CLIConfig renders the provider_installation block of the CLI config. It is appended to the
+// .terraformrc of the workspace, which therefore must not declare a provider_installation block itself.
+func (m ProviderMirror) CLIConfig() []byte {
+	if m.NetworkURL == "" && m.FilesystemPath == "" {
+		return nil
+	}
+
+	f := hclwrite.NewEmptyFile()
+	f.Body().AppendNewline()
+	installation := f.Body().AppendNewBlock("provider_installation", nil)
+	if m.NetworkURL != "" {
+		installation.Body().AppendNewBlock("network_mirror", nil).Body().
+			SetAttributeValue("url", cty.StringVal(m.NetworkURL))
+	}
+	if m.FilesystemPath != "" {
+		installation.Body().AppendNewBlock("filesystem_mirror", nil).Body().
+			SetAttributeValue("path", cty.StringVal(m.FilesystemPath))
+	}
+
+	return f.Bytes()
+}
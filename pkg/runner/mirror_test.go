@@ -0,0 +1,176 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeTerraform = "#!/bin/sh\necho fake terraform\n"
+
+func newSigningKey(t *testing.T) (*openpgp.Entity, string) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("mirror", "", "mirror@example.com", nil)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+
+	return entity, buf.String()
+}
+
+// terraformMirror serves a single terraform release laid out like releases.hashicorp.com,
+// with the checksums signed by signer.
+func terraformMirror(t *testing.T, v string, signer *openpgp.Entity) *httptest.Server {
+	t.Helper()
+	archiveName := fmt.Sprintf("terraform_%s_%s_%s.zip", v, runtime.GOOS, runtime.GOARCH)
+	archive := zipBinary(t, "terraform", fakeTerraform)
+	sum := sha256.Sum256(archive)
+	sums := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), archiveName)
+
+	var sig bytes.Buffer
+	require.NoError(t, openpgp.DetachSign(&sig, signer, bytes.NewReader([]byte(sums)), nil))
+
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/terraform/index.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"name":"terraform","versions":{%q:{},"not-a-version":{}}}`, v)
+	})
+	mux.HandleFunc(fmt.Sprintf("/terraform/%s/index.json", v), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"name":              "terraform",
+			"version":           v,
+			"shasums":           fmt.Sprintf("terraform_%s_SHA256SUMS", v),
+			"shasums_signature": fmt.Sprintf("terraform_%s_SHA256SUMS.sig", v),
+			"builds": []map[string]string{{
+				"name":     "terraform",
+				"version":  v,
+				"os":       runtime.GOOS,
+				"arch":     runtime.GOARCH,
+				"filename": archiveName,
+				"url":      fmt.Sprintf("%s/terraform/%s/%s", srv.URL, v, archiveName),
+			}},
+		})
+	})
+	mux.HandleFunc(fmt.Sprintf("/terraform/%s/terraform_%s_SHA256SUMS", v, v), func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(sums))
+	})
+	mux.HandleFunc(fmt.Sprintf("/terraform/%s/terraform_%s_SHA256SUMS.sig", v, v), func(w http.ResponseWriter, r *http.Request) {
+		w.Write(sig.Bytes())
+	})
+	mux.HandleFunc(fmt.Sprintf("/terraform/%s/%s", v, archiveName), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Write(archive)
+	})
+
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTerraformInstallerMirror(t *testing.T) {
+	signer, publicKey := newSigningKey(t)
+	srv := terraformMirror(t, "1.11.2", signer)
+
+	dir := t.TempDir()
+	installer := &TerraformInstaller{InstallDir: dir, BaseURL: srv.URL, ArmoredPublicKey: publicKey}
+	path, err := installer.Install(context.Background(), version.Must(version.NewVersion("1.11.2")))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "1.11.2", "terraform"), path)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, fakeTerraform, string(content))
+
+	versions, err := installer.Versions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []*version.Version{version.Must(version.NewVersion("1.11.2"))}, versions)
+}
+
+func TestTerraformInstallerMirrorUntrustedSignature(t *testing.T) {
+	signer, _ := newSigningKey(t)
+	_, otherKey := newSigningKey(t)
+	srv := terraformMirror(t, "1.11.2", signer)
+
+	dir := t.TempDir()
+	installer := &TerraformInstaller{InstallDir: dir, BaseURL: srv.URL, ArmoredPublicKey: otherKey}
+	_, err := installer.Install(context.Background(), version.Must(version.NewVersion("1.11.2")))
+	assert.ErrorContains(t, err, "signature")
+
+	_, err = os.Stat(filepath.Join(dir, "1.11.2", "terraform"))
+	assert.True(t, os.IsNotExist(err), "unverified binary must not be installed")
+}
+
+func TestLocalDirInstaller(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "1.11.2"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1.11.2", "terraform"), []byte(fakeTerraform), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "1.10.0"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "notes"), 0755))
+
+	installer := &LocalDirInstaller{Dir: dir, Binary: "terraform"}
+	path, err := installer.Install(context.Background(), version.Must(version.NewVersion("1.11.2")))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "1.11.2", "terraform"), path)
+
+	_, err = installer.Install(context.Background(), version.Must(version.NewVersion("1.10.0")))
+	assert.ErrorContains(t, err, "is not available")
+
+	versions, err := installer.Versions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []*version.Version{version.Must(version.NewVersion("1.11.2"))}, versions)
+}
+
+func TestProviderMirrorCLIConfig(t *testing.T) {
+	assert.Nil(t, ProviderMirror{}.CLIConfig())
+
+	config := ProviderMirror{NetworkURL: "https://mirror.example.com/providers/", FilesystemPath: "/mirror"}.CLIConfig()
+	assert.Equal(t, `
+provider_installation {
+  network_mirror {
+    url = "https://mirror.example.com/providers/"
+  }
+  filesystem_mirror {
+    path = "/mirror"
+  }
+}
+`, string(config))
+}
+
+func TestGetTerraformForWorkspaceWritesProviderMirror(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "1.11.2"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1.11.2", "terraform"), []byte(fakeTerraform), 0755))
+
+	e := New(t.TempDir())
+	e.SetTerraformDir(dir)
+	e.ProviderMirror = &ProviderMirror{FilesystemPath: "/mirror"}
+
+	tf, _, err := e.GetTerraformForWorkspace(context.Background(), newTestWorkspace(), version.Must(version.NewVersion("1.11.2")), "plugin_cache_dir = \"/cache\"\n")
+	require.NoError(t, err)
+
+	rc, err := os.ReadFile(filepath.Join(tf.WorkingDir(), ".terraformrc"))
+	require.NoError(t, err)
+	assert.Contains(t, string(rc), "plugin_cache_dir = \"/cache\"\n")
+	assert.Contains(t, string(rc), "filesystem_mirror {\n    path = \"/mirror\"\n  }")
+}
@@ -0,0 +1,259 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// PluginCache is a provider plugin cache shared by all workspaces, so each provider version is only
+// downloaded once. Terraform does not support concurrent writes to the cache, so inits using it are
+// serialized with a flock on the cache directory, both within the operator and across processes sharing the
+// directory. Eviction happens under the same lock, so it never removes a provider an init is about to link.
+type PluginCache struct {
+	Dir string
+	// MaxBytes is the size the cache is trimmed to after each init by evicting provider versions no
+	// workspace uses, least recently used first. Zero disables eviction.
+	MaxBytes int64
+
+	workspacesDir string
+	runsDir       string
+}
+
+// pluginCacheLockRetry is how often a lock on the cache is tried again while waiting for it.
+const pluginCacheLockRetry = 100 * time.Millisecond
+
+func newPluginCache(dir, workspacesDir, runsDir string) *PluginCache {
+	return &PluginCache{Dir: dir, workspacesDir: workspacesDir, runsDir: runsDir}
+}
+
+// Env returns the environment variables that make terraform use the cache. Terraform only links a cached
+// provider once the lock file records its checksums, so a workspace without a lock file downloads its
+// providers once to record them in full.
+func (c *PluginCache) Env() map[string]string {
+	return map[string]string{
+		"TF_PLUGIN_CACHE_DIR": c.Dir,
+	}
+}
+
+// Init runs terraform init while holding the cache lock, records cache hits and misses of the providers
+// the workspace ended up with and evicts unused providers if the cache has grown beyond MaxBytes.
+func (c *PluginCache) Init(ctx context.Context, tf Terraform, upgrade bool) error {
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	before, err := c.entries()
+	if err != nil {
+		return err
+	}
+
+	err = tf.Init(ctx, upgrade)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, entry := range providerEntries(filepath.Join(tf.WorkingDir(), ".terraform", "providers")) {
+		if _, ok := before[entry]; ok {
+			pluginCacheHits.Inc()
+		} else {
+			pluginCacheMisses.Inc()
+		}
+		// The modification time is what eviction uses to find the least recently used providers
+		_ = os.Chtimes(filepath.Join(c.Dir, entry), now, now)
+	}
+
+	return c.evict()
+}
+
+// lock waits for an exclusive flock on the cache directory until ctx is done. The returned function releases
+// it and may be called more than once.
+func (c *PluginCache) lock(ctx context.Context) (func(), error) {
+	err := os.MkdirAll(c.Dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create plugin cache dir: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(c.Dir, ".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin cache lock: %w", err)
+	}
+
+	for {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock plugin cache: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, fmt.Errorf("waiting for plugin cache lock: %w", ctx.Err())
+		case <-time.After(pluginCacheLockRetry):
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+			f.Close()
+		})
+	}, nil
+}
+
+type cacheEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// entries lists the provider versions in the cache keyed by <host>/<namespace>/<type>/<version>/<os_arch>.
+func (c *PluginCache) entries() (map[string]cacheEntry, error) {
+	entries := map[string]cacheEntry{}
+	for _, key := range providerEntries(c.Dir) {
+		path := filepath.Join(c.Dir, key)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		size, err := dirSize(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get size of %s: %w", path, err)
+		}
+		entries[key] = cacheEntry{path: path, size: size, modTime: info.ModTime()}
+	}
+
+	return entries, nil
+}
+
+// evict removes provider versions no workspace uses until the cache is no bigger than MaxBytes. It must be
+// called with the cache lock held.
+func (c *PluginCache) evict() error {
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+	defer func() { pluginCacheSize.Set(float64(total)) }()
+
+	if c.MaxBytes <= 0 || total <= c.MaxBytes {
+		return nil
+	}
+
+	used, err := c.usedEntries()
+	if err != nil {
+		return err
+	}
+
+	var unused []cacheEntry
+	for key, e := range entries {
+		if !used[key] {
+			unused = append(unused, e)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool { return unused[i].modTime.Before(unused[j].modTime) })
+
+	for _, e := range unused {
+		if total <= c.MaxBytes {
+			break
+		}
+		err = os.RemoveAll(e.path)
+		if err != nil {
+			return fmt.Errorf("failed to evict %s: %w", e.path, err)
+		}
+		removeEmptyParents(filepath.Dir(e.path), c.Dir)
+		total -= e.size
+		pluginCacheEvictions.Inc()
+	}
+
+	return nil
+}
+
+// usedEntries returns the cache entries installed in any workspace or run in progress.
+func (c *PluginCache) usedEntries() (map[string]bool, error) {
+	dirs, err := filepath.Glob(filepath.Join(c.workspacesDir, "*", "*", ".terraform", "providers"))
+	if err != nil {
+		return nil, err
+	}
+	runDirs, err := filepath.Glob(filepath.Join(c.runsDir, "*", "*", "*", ".terraform", "providers"))
+	if err != nil {
+		return nil, err
+	}
+	dirs = append(dirs, runDirs...)
+
+	used := map[string]bool{}
+	for _, dir := range dirs {
+		for _, key := range providerEntries(dir) {
+			used[key] = true
+		}
+	}
+
+	return used, nil
+}
+
+// providerEntries lists the <host>/<namespace>/<type>/<version>/<os_arch> directories of a provider
+// directory, which is laid out the same way in the cache and in .terraform/providers of a workspace.
+func providerEntries(dir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*", "*", "*", "*", "*_*"))
+
+	var entries []string
+	for _, match := range matches {
+		rel, err := filepath.Rel(dir, match)
+		if err != nil || strings.HasPrefix(filepath.Base(rel), ".") {
+			continue
+		}
+		entries = append(entries, rel)
+	}
+
+	return entries
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+
+	return size, err
+}
+
+// removeEmptyParents removes dir and its parents up to, but not including, root as long as they are empty.
+func removeEmptyParents(dir, root string) {
+	for dir != root && strings.HasPrefix(dir, root) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,169 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeInit pretends to init a workspace using hashicorp/null 3.2.3 the way terraform does with a plugin
+// cache: the provider is downloaded into the cache unless it is there and linked into the workspace.
+const fakeInit = `#!/bin/sh
+case "$1" in
+version) echo '{"terraform_version":"1.11.2"}'; exit 0;;
+esac
+entry=registry.terraform.io/hashicorp/null/3.2.3/linux_amd64
+if [ ! -d "$TF_PLUGIN_CACHE_DIR/$entry" ]; then
+  mkdir -p "$TF_PLUGIN_CACHE_DIR/$entry"
+  echo provider > "$TF_PLUGIN_CACHE_DIR/$entry/terraform-provider-null"
+fi
+mkdir -p .terraform/providers/registry.terraform.io/hashicorp/null/3.2.3
+ln -sfn "$TF_PLUGIN_CACHE_DIR/$entry" .terraform/providers/$entry
+`
+
+// exclusiveInit fails if another init is using the cache at the same time.
+const exclusiveInit = `#!/bin/sh
+case "$1" in
+version) echo '{"terraform_version":"1.11.2"}'; exit 0;;
+esac
+if ls "$TF_PLUGIN_CACHE_DIR" | grep -q started-; then
+  echo "inits overlap" >&2
+  exit 1
+fi
+touch "$TF_PLUGIN_CACHE_DIR/started-$$"
+sleep 0.1
+rm "$TF_PLUGIN_CACHE_DIR/started-$$"
+`
+
+func newFakeInitWorkspace(t *testing.T, e *Exec, name string) Terraform {
+	t.Helper()
+	return newScriptWorkspace(t, e, name, fakeInit)
+}
+
+func newScriptWorkspace(t *testing.T, e *Exec, name, script string) Terraform {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "terraform")
+	require.NoError(t, os.WriteFile(bin, []byte(script), 0755))
+
+	dir, err := e.SetupWorkspace(filepath.Join("default", name))
+	require.NoError(t, err)
+	tf, err := NewTFExec(dir, bin)
+	require.NoError(t, err)
+	require.NoError(t, tf.SetEnv(e.PluginCache.Env()))
+	return tf
+}
+
+func TestPluginCacheInit(t *testing.T) {
+	e := New(t.TempDir())
+	hits := testutil.ToFloat64(pluginCacheHits)
+	misses := testutil.ToFloat64(pluginCacheMisses)
+
+	first := newFakeInitWorkspace(t, e, "first")
+	require.NoError(t, e.Init(context.Background(), first, false))
+	assert.Equal(t, misses+1, testutil.ToFloat64(pluginCacheMisses))
+	assert.Equal(t, hits, testutil.ToFloat64(pluginCacheHits))
+
+	second := newFakeInitWorkspace(t, e, "second")
+	require.NoError(t, e.Init(context.Background(), second, false))
+	assert.Equal(t, misses+1, testutil.ToFloat64(pluginCacheMisses))
+	assert.Equal(t, hits+1, testutil.ToFloat64(pluginCacheHits))
+	assert.Equal(t, float64(len("provider\n")), testutil.ToFloat64(pluginCacheSize))
+}
+
+func TestPluginCacheInitsAreSerialized(t *testing.T) {
+	e := New(t.TempDir())
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		tf := newScriptWorkspace(t, e, "ws"+string(rune('a'+i)), exclusiveInit)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = e.Init(context.Background(), tf, false)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+}
+
+func TestPluginCacheInitWaitsForLock(t *testing.T) {
+	e := New(t.TempDir())
+	tf := newFakeInitWorkspace(t, e, "ws")
+
+	// Another init holds the lock, an init waiting for it gives up with its context
+	unlock, err := e.PluginCache.lock(context.Background())
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	err = e.Init(ctx, tf, false)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NoDirExists(t, filepath.Join(tf.WorkingDir(), ".terraform"), "init must not run without the lock")
+
+	unlock()
+	require.NoError(t, e.Init(context.Background(), tf, false))
+}
+
+func writeCacheEntry(t *testing.T, dir, entry string, size int, modTime time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, entry)
+	require.NoError(t, os.MkdirAll(path, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(path, "provider"), make([]byte, size), 0755))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestPluginCacheEvict(t *testing.T) {
+	e := New(t.TempDir())
+	cache := e.PluginCache
+	now := time.Now()
+
+	writeCacheEntry(t, cache.Dir, "registry.terraform.io/hashicorp/aws/5.0.0/linux_amd64", 100, now.Add(-3*time.Hour))
+	writeCacheEntry(t, cache.Dir, "registry.terraform.io/hashicorp/aws/5.1.0/linux_amd64", 100, now.Add(-2*time.Hour))
+	writeCacheEntry(t, cache.Dir, "registry.terraform.io/hashicorp/null/3.2.3/linux_amd64", 100, now.Add(-time.Hour))
+	writeCacheEntry(t, cache.Dir, "registry.terraform.io/hashicorp/aws/4.0.0/linux_amd64", 100, now.Add(-4*time.Hour))
+
+	// The oldest provider is still used by a workspace and must survive
+	used := filepath.Join(e.WorkspacesDir, "default", "ws", ".terraform", "providers", "registry.terraform.io/hashicorp/aws/4.0.0")
+	require.NoError(t, os.MkdirAll(used, 0755))
+	require.NoError(t, os.Symlink(filepath.Join(cache.Dir, "registry.terraform.io/hashicorp/aws/4.0.0/linux_amd64"), filepath.Join(used, "linux_amd64")))
+	// As is one used by a run in progress
+	running := filepath.Join(e.RunsDir, "default", "ws", "run-1", ".terraform", "providers", "registry.terraform.io/hashicorp/aws/5.1.0")
+	require.NoError(t, os.MkdirAll(running, 0755))
+	require.NoError(t, os.Symlink(filepath.Join(cache.Dir, "registry.terraform.io/hashicorp/aws/5.1.0/linux_amd64"), filepath.Join(running, "linux_amd64")))
+
+	cache.MaxBytes = 250
+	require.NoError(t, cache.evict())
+
+	assert.DirExists(t, filepath.Join(cache.Dir, "registry.terraform.io/hashicorp/aws/4.0.0/linux_amd64"))
+	assert.NoDirExists(t, filepath.Join(cache.Dir, "registry.terraform.io/hashicorp/aws/5.0.0"))
+	assert.DirExists(t, filepath.Join(cache.Dir, "registry.terraform.io/hashicorp/aws/5.1.0/linux_amd64"))
+	assert.NoDirExists(t, filepath.Join(cache.Dir, "registry.terraform.io/hashicorp/null"))
+	assert.Equal(t, float64(200), testutil.ToFloat64(pluginCacheSize))
+}
+
+func TestInitIfChanged(t *testing.T) {
+	e := New(t.TempDir())
+	tf := newFakeInitWorkspace(t, e, "ws")
+
+	ran, err := e.InitIfChanged(context.Background(), tf, "hash-1", false)
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	ran, err = e.InitIfChanged(context.Background(), tf, "hash-1", false)
+	require.NoError(t, err)
+	assert.False(t, ran, ".terraform already matches the inputs")
+
+	ran, err = e.InitIfChanged(context.Background(), tf, "hash-2", false)
+	require.NoError(t, err)
+	assert.True(t, ran)
+}
@@ -0,0 +1,140 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+const (
+	// ErroredStateFile is where terraform writes the state when it fails to persist it to the backend.
+	ErroredStateFile = "errored.tfstate"
+	// localLockInfoFile is written next to the state by the local backend while it holds the state lock.
+	localLockInfoFile = ".terraform.tfstate.lock.info"
+)
+
+type lockInfo struct {
+	ID        string `json:"ID"`
+	Path      string `json:"Path"`
+	Operation string `json:"Operation"`
+	Who       string `json:"Who"`
+	Created   string `json:"Created"`
+}
+
+// DetectRecovery looks for what an interrupted run may have left in dir, the directory of a workspace or a
+// run: an errored state file, or the lock info of the local backend. It returns nil if there is nothing to
+// recover.
+func (e *Exec) DetectRecovery(dir string) (*tfreconcilev1alpha1.StateRecoveryStatus, error) {
+	recovery := &tfreconcilev1alpha1.StateRecoveryStatus{}
+
+	if _, err := os.Stat(filepath.Join(dir, ErroredStateFile)); err == nil {
+		recovery.ErroredState = true
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, localLockInfoFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read lock info: %w", err)
+	}
+	if err == nil {
+		var info lockInfo
+		err = json.Unmarshal(content, &info)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse lock info: %w", err)
+		}
+		recovery.Lock = &tfreconcilev1alpha1.StateLock{
+			ID:        info.ID,
+			Path:      info.Path,
+			Operation: info.Operation,
+			Who:       info.Who,
+			Created:   info.Created,
+		}
+	}
+
+	if !recovery.ErroredState && recovery.Lock == nil {
+		return nil, nil
+	}
+	return recovery, nil
+}
+
+var lockInfoLine = regexp.MustCompile(`(?m)^\s*(ID|Path|Operation|Who|Created):\s*(.*?)\s*$`)
+
+// ParseLockError extracts the lock info from the error of a terraform command that failed to acquire the
+// state lock. It returns nil if the error is not a lock error.
+func ParseLockError(err error) *tfreconcilev1alpha1.StateLock {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	_, info, ok := strings.Cut(msg, "Lock Info:")
+	if !ok || !strings.Contains(msg, "Error acquiring the state lock") {
+		return nil
+	}
+
+	lock := &tfreconcilev1alpha1.StateLock{}
+	for _, m := range lockInfoLine.FindAllStringSubmatch(info, -1) {
+		switch m[1] {
+		case "ID":
+			lock.ID = m[2]
+		case "Path":
+			lock.Path = m[2]
+		case "Operation":
+			lock.Operation = m[2]
+		case "Who":
+			lock.Who = m[2]
+		case "Created":
+			lock.Created = m[2]
+		}
+	}
+	if lock.ID == "" {
+		return nil
+	}
+	return lock
+}
+
+// PushErroredState pushes errored.tfstate to the backend of the workspace. The file is kept, renamed with
+// the time it was pushed, in case it is needed again.
+func (e *Exec) PushErroredState(ctx context.Context, tf Terraform) error {
+	path := filepath.Join(tf.WorkingDir(), ErroredStateFile)
+	err := tf.StatePush(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to push errored state: %w", err)
+	}
+
+	err = os.Rename(path, fmt.Sprintf("%s.pushed-%s", path, time.Now().UTC().Format("20060102T150405Z")))
+	if err != nil {
+		return fmt.Errorf("failed to move pushed errored state aside: %w", err)
+	}
+
+	return nil
+}
+
+// ForceUnlock removes the lock with the given ID from the state of the workspace. A lock left by the local
+// backend is only lock info, the lock itself died with the process that held it, so the info is removed.
+// Any other lock is removed with terraform force-unlock.
+func (e *Exec) ForceUnlock(ctx context.Context, tf Terraform, lockID string) error {
+	infoPath := filepath.Join(tf.WorkingDir(), localLockInfoFile)
+	if content, err := os.ReadFile(infoPath); err == nil {
+		var info lockInfo
+		if json.Unmarshal(content, &info) == nil && info.ID == lockID {
+			err = os.Remove(infoPath)
+			if err != nil {
+				return fmt.Errorf("failed to remove lock info: %w", err)
+			}
+			return nil
+		}
+	}
+
+	err := tf.ForceUnlock(ctx, lockID)
+	if err != nil {
+		return fmt.Errorf("failed to force-unlock state: %w", err)
+	}
+
+	return nil
+}
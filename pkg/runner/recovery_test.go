@@ -0,0 +1,130 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+const lockError = `exit status 1
+
+Error: Error acquiring the state lock
+
+Error message: ConditionalCheckFailedException: The conditional request failed
+Lock Info:
+  ID:        0c3b5a4e-7a56-6f1e-2b2c-9b0c1e8e2d3f
+  Path:      my-bucket/default/terraform.tfstate
+  Operation: OperationTypeApply
+  Who:       krec@operator-7d9f
+  Version:   1.11.2
+  Created:   2026-10-19 07:12:43.123 +0000 UTC
+  Info:
+
+
+Terraform acquires a state lock to protect the state from being written
+by multiple users at the same time.
+`
+
+func TestParseLockError(t *testing.T) {
+	lock := ParseLockError(errors.New(lockError))
+	assert.Equal(t, &tfreconcilev1alpha1.StateLock{
+		ID:        "0c3b5a4e-7a56-6f1e-2b2c-9b0c1e8e2d3f",
+		Path:      "my-bucket/default/terraform.tfstate",
+		Operation: "OperationTypeApply",
+		Who:       "krec@operator-7d9f",
+		Created:   "2026-10-19 07:12:43.123 +0000 UTC",
+	}, lock)
+
+	assert.Nil(t, ParseLockError(nil))
+	assert.Nil(t, ParseLockError(errors.New("exit status 1\n\nError: Invalid reference")))
+}
+
+func TestDetectRecovery(t *testing.T) {
+	e := New(t.TempDir())
+	ws := newTestWorkspace()
+	dir, err := e.SetupWorkspace(workspaceKey(ws))
+	require.NoError(t, err)
+
+	recovery, err := e.DetectRecovery(dir)
+	require.NoError(t, err)
+	assert.Nil(t, recovery)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ErroredStateFile), []byte(`{}`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, localLockInfoFile),
+		[]byte(`{"ID":"1234","Operation":"OperationTypeApply","Who":"krec@pod","Created":"2026-10-19T07:12:43Z","Path":"terraform.tfstate"}`), 0600))
+
+	recovery, err = e.DetectRecovery(dir)
+	require.NoError(t, err)
+	assert.Equal(t, &tfreconcilev1alpha1.StateRecoveryStatus{
+		ErroredState: true,
+		Lock: &tfreconcilev1alpha1.StateLock{
+			ID:        "1234",
+			Path:      "terraform.tfstate",
+			Operation: "OperationTypeApply",
+			Who:       "krec@pod",
+			Created:   "2026-10-19T07:12:43Z",
+		},
+	}, recovery)
+}
+
+// fakeStateCmds records the state commands it is asked to run in calls.log.
+const fakeStateCmds = `#!/bin/sh
+case "$1" in
+version) echo '{"terraform_version":"1.11.2"}'; exit 0;;
+esac
+echo "$@" >> calls.log
+`
+
+func newFakeStateWorkspace(t *testing.T, e *Exec) Terraform {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "terraform")
+	require.NoError(t, os.WriteFile(bin, []byte(fakeStateCmds), 0755))
+
+	dir, err := e.SetupWorkspace(workspaceKey(newTestWorkspace()))
+	require.NoError(t, err)
+	tf, err := NewTFExec(dir, bin)
+	require.NoError(t, err)
+	return tf
+}
+
+func TestPushErroredState(t *testing.T) {
+	e := New(t.TempDir())
+	tf := newFakeStateWorkspace(t, e)
+	require.NoError(t, os.WriteFile(filepath.Join(tf.WorkingDir(), ErroredStateFile), []byte(`{}`), 0600))
+
+	require.NoError(t, e.PushErroredState(context.Background(), tf))
+
+	calls, err := os.ReadFile(filepath.Join(tf.WorkingDir(), "calls.log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(calls), "state push")
+	assert.Contains(t, string(calls), ErroredStateFile)
+	assert.NoFileExists(t, filepath.Join(tf.WorkingDir(), ErroredStateFile))
+	pushed, err := filepath.Glob(filepath.Join(tf.WorkingDir(), ErroredStateFile+".pushed-*"))
+	require.NoError(t, err)
+	assert.Len(t, pushed, 1, "the pushed state is kept aside")
+}
+
+func TestForceUnlock(t *testing.T) {
+	e := New(t.TempDir())
+	tf := newFakeStateWorkspace(t, e)
+
+	// Lock info left by the local backend is removed without running terraform
+	infoPath := filepath.Join(tf.WorkingDir(), localLockInfoFile)
+	require.NoError(t, os.WriteFile(infoPath, []byte(`{"ID":"local-lock"}`), 0600))
+	require.NoError(t, e.ForceUnlock(context.Background(), tf, "local-lock"))
+	assert.NoFileExists(t, infoPath)
+	assert.NoFileExists(t, filepath.Join(tf.WorkingDir(), "calls.log"))
+
+	// Any other lock is removed by terraform
+	require.NoError(t, e.ForceUnlock(context.Background(), tf, "remote-lock"))
+	calls, err := os.ReadFile(filepath.Join(tf.WorkingDir(), "calls.log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(calls), "force-unlock")
+	assert.Contains(t, string(calls), "remote-lock")
+}
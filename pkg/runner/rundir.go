@@ -0,0 +1,311 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+// Every run of a workspace gets a directory of its own under RunsDir, so a run never sees the rendered
+// configuration, plan files or CLI config of another. The directory starts out with the artifacts of the
+// workspace directory worth keeping and, once the run ends, replaces the workspace directory with only those
+// artifacts left. Runs of the same workspace are serialized by a lock held for the whole run.
+
+// keptArtifacts are the top level entries of a run directory kept for the next run, as glob patterns.
+var keptArtifacts = []string{
+	// Providers, modules and backend config installed by init, and the dependency lock file
+	".terraform",
+	".terraform.lock.hcl",
+	// State of the local backend
+	"terraform.tfstate",
+	"terraform.tfstate.backup",
+	"terraform.tfstate.d",
+	localLockInfoFile,
+	ErroredStateFile,
+	ErroredStateFile + ".pushed-*",
+}
+
+// runMarkerFile marks a run directory as fully set up, so that after a crash it can be told apart from one
+// that was still being copied.
+const runMarkerFile = ".krec-run"
+
+func isKeptArtifact(name string) bool {
+	for _, pattern := range keptArtifacts {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// workspaceLocks are locks keyed by workspace that can be waited for with a context.
+type workspaceLocks struct {
+	mu    sync.Mutex
+	locks map[string]chan struct{}
+}
+
+func newWorkspaceLocks() *workspaceLocks {
+	return &workspaceLocks{locks: map[string]chan struct{}{}}
+}
+
+func (l *workspaceLocks) lock(ctx context.Context, key string) (func(), error) {
+	l.mu.Lock()
+	ch, ok := l.locks[key]
+	if !ok {
+		ch = make(chan struct{}, 1)
+		l.locks[key] = ch
+	}
+	l.mu.Unlock()
+
+	select {
+	case ch <- struct{}{}:
+		return func() { <-ch }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// runVersions records the engine version each run in progress uses, so the janitor never removes an install
+// out from under a run that has not recorded its version in the workspace status yet.
+type runVersions struct {
+	mu sync.Mutex
+	// runs maps run directories to <engine>/<version>
+	runs map[string]string
+}
+
+func newRunVersions() *runVersions {
+	return &runVersions{runs: map[string]string{}}
+}
+
+func (v *runVersions) add(dir, version string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.runs[dir] = version
+}
+
+func (v *runVersions) remove(dir string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.runs, dir)
+}
+
+// lockUsed locks out runs from starting to use a version and returns the versions in use until unlocked.
+func (v *runVersions) lockUsed() (map[string]bool, func()) {
+	v.mu.Lock()
+	used := map[string]bool{}
+	for _, version := range v.runs {
+		used[version] = true
+	}
+	return used, v.mu.Unlock
+}
+
+// WorkspaceDir returns the directory the artifacts of the workspace are kept in between runs.
+func (e *Exec) WorkspaceDir(ws tfreconcilev1alpha1.Workspace) string {
+	return filepath.Join(e.WorkspacesDir, workspaceKey(ws))
+}
+
+// LockWorkspace waits until no other run of the workspace is in progress and locks it until the returned
+// function is called. Run directories left behind by a crash are dealt with first: a run that was fully set up
+// is kept as if it had ended, since it may have changed the local state, anything else is removed.
+func (e *Exec) LockWorkspace(ctx context.Context, ws tfreconcilev1alpha1.Workspace) (func(), error) {
+	key := workspaceKey(ws)
+	unlock, err := e.locks.lock(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock workspace %s: %w", key, err)
+	}
+
+	err = e.salvageRuns(key)
+	if err != nil {
+		unlock()
+		return nil, fmt.Errorf("failed to clean up earlier runs of %s: %w", key, err)
+	}
+
+	return unlock, nil
+}
+
+func (e *Exec) salvageRuns(key string) error {
+	dir := filepath.Join(e.RunsDir, key)
+	base := filepath.Join(e.WorkspacesDir, key)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Entries are sorted by name, which puts a workspace directory moved aside before the runs and the runs
+	// in the order they were started
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		switch {
+		case strings.HasPrefix(entry.Name(), "prev-"):
+			// A run ended half way through replacing the workspace directory
+			if _, statErr := os.Stat(base); os.IsNotExist(statErr) {
+				err = os.MkdirAll(filepath.Dir(base), 0755)
+				if err == nil {
+					err = os.Rename(path, base)
+				}
+			} else {
+				err = os.RemoveAll(path)
+			}
+		case fileExists(filepath.Join(path, runMarkerFile)):
+			err = promoteRun(path, base)
+		default:
+			err = os.RemoveAll(path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newRunDir creates the directory of a new run of the workspace with the given key, with a copy of the
+// artifacts kept in the workspace directory.
+func (e *Exec) newRunDir(key string) (string, error) {
+	base := filepath.Join(e.WorkspacesDir, key)
+	parent := filepath.Join(e.RunsDir, key)
+	err := os.MkdirAll(parent, 0755)
+	if err != nil {
+		return "", fmt.Errorf("failed to create runs dir: %w", err)
+	}
+
+	dir, err := os.MkdirTemp(parent, "run-"+time.Now().UTC().Format("20060102T150405Z")+"-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create run dir: %w", err)
+	}
+
+	entries, err := os.ReadDir(base)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read workspace dir: %w", err)
+	}
+	for _, entry := range entries {
+		if !isKeptArtifact(entry.Name()) {
+			continue
+		}
+		err = copyTree(filepath.Join(base, entry.Name()), filepath.Join(dir, entry.Name()))
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("failed to copy %s into run dir: %w", entry.Name(), err)
+		}
+	}
+
+	err = os.WriteFile(filepath.Join(dir, runMarkerFile), nil, 0644)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to mark run dir: %w", err)
+	}
+
+	return dir, nil
+}
+
+// EndRun ends the run in dir, a directory returned by GetTerraformForWorkspace. With keep the artifacts of
+// the run worth keeping replace the workspace directory, otherwise the run is discarded, which is what
+// runs that changed nothing or deleted the workspace want.
+func (e *Exec) EndRun(ws tfreconcilev1alpha1.Workspace, dir string, keep bool) error {
+	e.runVersions.remove(dir)
+
+	if !keep {
+		err := os.RemoveAll(dir)
+		if err != nil {
+			return fmt.Errorf("failed to remove run dir: %w", err)
+		}
+		removeEmptyParents(filepath.Dir(dir), e.RunsDir)
+		return nil
+	}
+
+	err := promoteRun(dir, e.WorkspaceDir(ws))
+	if err != nil {
+		return fmt.Errorf("failed to keep artifacts of run: %w", err)
+	}
+
+	return nil
+}
+
+// promoteRun removes everything but the kept artifacts from the run directory and swaps it in for the
+// workspace directory base.
+func promoteRun(dir, base string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == runMarkerFile || isKeptArtifact(entry.Name()) {
+			continue
+		}
+		err = os.RemoveAll(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+	}
+
+	prev := filepath.Join(filepath.Dir(dir), "prev-"+filepath.Base(dir))
+	if _, err := os.Stat(base); err == nil {
+		err = os.Rename(base, prev)
+		if err != nil {
+			return err
+		}
+	}
+	err = os.MkdirAll(filepath.Dir(base), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(dir, base)
+	if err != nil {
+		return err
+	}
+
+	_ = os.Remove(filepath.Join(base, runMarkerFile))
+	return os.RemoveAll(prev)
+}
+
+// copyTree copies a file or directory, keeping symlinks as they are.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0755)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return writeFile(target, f, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
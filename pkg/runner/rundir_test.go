@@ -0,0 +1,167 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestRunDirKeepsOnlyArtifacts(t *testing.T) {
+	e := New(t.TempDir())
+	ws := newTestWorkspace()
+	base, err := e.SetupWorkspace(workspaceKey(ws))
+	require.NoError(t, err)
+	writeTestFile(t, filepath.Join(base, "main.tf"), "old config")
+	writeTestFile(t, filepath.Join(base, "terraform.tfstate"), "old state")
+	writeTestFile(t, filepath.Join(base, ErroredStateFile), "errored")
+	writeTestFile(t, filepath.Join(base, ".terraform", "modules", "modules.json"), "{}")
+	require.NoError(t, os.Symlink("/plugin-cache/aws", filepath.Join(base, ".terraform", "aws")))
+
+	dir, err := e.newRunDir(workspaceKey(ws))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(e.RunsDir, workspaceKey(ws)), filepath.Dir(dir))
+	assert.NoFileExists(t, filepath.Join(dir, "main.tf"))
+	assert.Equal(t, "old state", readTestFile(t, filepath.Join(dir, "terraform.tfstate")))
+	assert.FileExists(t, filepath.Join(dir, ".terraform", "modules", "modules.json"))
+	link, err := os.Readlink(filepath.Join(dir, ".terraform", "aws"))
+	require.NoError(t, err)
+	assert.Equal(t, "/plugin-cache/aws", link)
+
+	writeTestFile(t, filepath.Join(dir, "main.tf"), "new config")
+	writeTestFile(t, filepath.Join(dir, "plan.out"), "plan")
+	writeTestFile(t, filepath.Join(dir, ".terraformrc"), "rc")
+	writeTestFile(t, filepath.Join(dir, "terraform.tfstate"), "new state")
+	require.NoError(t, os.Remove(filepath.Join(dir, ErroredStateFile)))
+
+	require.NoError(t, e.EndRun(ws, dir, true))
+	assert.NoDirExists(t, dir)
+	entries, err := os.ReadDir(base)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.ElementsMatch(t, []string{".terraform", "terraform.tfstate"}, names)
+	assert.Equal(t, "new state", readTestFile(t, filepath.Join(base, "terraform.tfstate")))
+}
+
+func TestEndRunDiscards(t *testing.T) {
+	e := New(t.TempDir())
+	ws := newTestWorkspace()
+	base, err := e.SetupWorkspace(workspaceKey(ws))
+	require.NoError(t, err)
+	writeTestFile(t, filepath.Join(base, "terraform.tfstate"), "state")
+
+	dir, err := e.newRunDir(workspaceKey(ws))
+	require.NoError(t, err)
+	writeTestFile(t, filepath.Join(dir, "terraform.tfstate"), "discarded")
+
+	require.NoError(t, e.EndRun(ws, dir, false))
+	assert.NoDirExists(t, dir)
+	assert.NoDirExists(t, filepath.Join(e.RunsDir, workspaceKey(ws)))
+	assert.Equal(t, "state", readTestFile(t, filepath.Join(base, "terraform.tfstate")))
+}
+
+// failingInstaller fails every install.
+type failingInstaller struct{}
+
+func (failingInstaller) Install(ctx context.Context, v *version.Version) (string, error) {
+	return "", errors.New("download failed")
+}
+
+func (failingInstaller) Versions(ctx context.Context) ([]*version.Version, error) {
+	return nil, errors.New("download failed")
+}
+
+func TestGetTerraformForWorkspaceDiscardsRunOnError(t *testing.T) {
+	e := New(t.TempDir())
+	e.Installers[EngineTerraform] = failingInstaller{}
+	ws := newTestWorkspace()
+	writeTestFile(t, filepath.Join(e.WorkspaceDir(ws), "terraform.tfstate"), "state")
+
+	_, _, err := e.GetTerraformForWorkspace(context.Background(), ws, version.Must(version.NewVersion("1.9.0")), "")
+	assert.ErrorContains(t, err, "download failed")
+	assert.NoDirExists(t, filepath.Join(e.RunsDir, workspaceKey(ws)), "the run never started")
+	used, unlock := e.runVersions.lockUsed()
+	unlock()
+	assert.Empty(t, used)
+
+	unlockWorkspace, err := e.LockWorkspace(context.Background(), ws)
+	require.NoError(t, err)
+	unlockWorkspace()
+	assert.Equal(t, "state", readTestFile(t, filepath.Join(e.WorkspaceDir(ws), "terraform.tfstate")))
+}
+
+func TestLockWorkspace(t *testing.T) {
+	e := New(t.TempDir())
+	ws := newTestWorkspace()
+
+	unlock, err := e.LockWorkspace(context.Background(), ws)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = e.LockWorkspace(ctx, ws)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	other := newTestWorkspace()
+	other.Name = "other"
+	unlockOther, err := e.LockWorkspace(context.Background(), other)
+	require.NoError(t, err)
+	unlockOther()
+
+	unlock()
+	unlock, err = e.LockWorkspace(context.Background(), ws)
+	require.NoError(t, err)
+	unlock()
+}
+
+func TestLockWorkspaceSalvagesCrashedRuns(t *testing.T) {
+	e := New(t.TempDir())
+	ws := newTestWorkspace()
+	key := workspaceKey(ws)
+	base := e.WorkspaceDir(ws)
+
+	// A run that ended half way through replacing the workspace directory
+	prev := filepath.Join(e.RunsDir, key, "prev-run-20261019T000000Z-1")
+	writeTestFile(t, filepath.Join(prev, "terraform.tfstate"), "state 1")
+	// A run that crashed after it was set up, which may have written state
+	crashed := filepath.Join(e.RunsDir, key, "run-20261019T000001Z-2")
+	writeTestFile(t, filepath.Join(crashed, runMarkerFile), "")
+	writeTestFile(t, filepath.Join(crashed, "terraform.tfstate"), "state 2")
+	writeTestFile(t, filepath.Join(crashed, "plan.out"), "plan")
+	// A run that crashed while it was being set up
+	partial := filepath.Join(e.RunsDir, key, "run-20261019T000002Z-3")
+	writeTestFile(t, filepath.Join(partial, "terraform.tfstate"), "partial")
+
+	unlock, err := e.LockWorkspace(context.Background(), ws)
+	require.NoError(t, err)
+	defer unlock()
+
+	assert.Equal(t, "state 2", readTestFile(t, filepath.Join(base, "terraform.tfstate")))
+	assert.NoFileExists(t, filepath.Join(base, "plan.out"))
+	assert.NoFileExists(t, filepath.Join(base, runMarkerFile))
+	entries, err := os.ReadDir(filepath.Join(e.RunsDir, key))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
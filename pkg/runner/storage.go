@@ -0,0 +1,352 @@
+package runner
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Storage persists workspace directories so they survive restarts of the operator. Workspaces are
+// identified by a key of the form <namespace>/<name>.
+type Storage interface {
+	// Restore brings back the directory of a workspace if it is missing locally. It does nothing if the
+	// directory exists or nothing has been saved for the workspace yet.
+	Restore(ctx context.Context, key, dir string) error
+	// Save persists the directory of a workspace.
+	Save(ctx context.Context, key, dir string) error
+	// Delete removes everything persisted for a workspace.
+	Delete(ctx context.Context, key string) error
+	// List returns the keys of the workspaces something is persisted for, with when it was last saved.
+	List(ctx context.Context) (map[string]time.Time, error)
+}
+
+// LocalStorage keeps workspace directories on the local disk only. Whether they survive a restart depends
+// on the volume RootDir is on, which is the case for a PersistentVolumeClaim but not for an emptyDir.
+type LocalStorage struct{}
+
+func (LocalStorage) Restore(ctx context.Context, key, dir string) error { return nil }
+
+func (LocalStorage) Save(ctx context.Context, key, dir string) error { return nil }
+
+func (LocalStorage) Delete(ctx context.Context, key string) error { return nil }
+
+func (LocalStorage) List(ctx context.Context) (map[string]time.Time, error) { return nil, nil }
+
+// snapshotExcludes are the paths of a workspace directory left out of snapshots. Providers are symlinks into
+// the plugin cache, which is not part of the snapshot, so they are installed again by the next init.
+var snapshotExcludes = []string{
+	filepath.Join(".terraform", "providers"),
+	filepath.Join(".terraform", initHashFile),
+}
+
+// S3Storage snapshots each workspace directory as a gzipped tarball into a bucket of an S3 compatible
+// object store, such as AWS S3 or MinIO.
+type S3Storage struct {
+	Client *minio.Client
+	Bucket string
+	// Prefix is prepended to the object key of every snapshot.
+	Prefix string
+}
+
+// NewS3Storage creates an S3Storage for the given endpoint, such as https://s3.eu-west-1.amazonaws.com or
+// http://minio:9000. Credentials are read from the AWS or MinIO environment variables, or the instance metadata.
+func NewS3Storage(endpoint, region, bucket, prefix string) (*S3Storage, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q: missing host", endpoint)
+	}
+
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+		&credentials.IAM{},
+	})
+
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:  creds,
+		Secure: u.Scheme != "http",
+		Region: region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	return &S3Storage{Client: client, Bucket: bucket, Prefix: prefix}, nil
+}
+
+func (s *S3Storage) objectName(key string) string {
+	return path.Join(s.Prefix, key+".tar.gz")
+}
+
+func (s *S3Storage) Restore(ctx context.Context, key, dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+
+	obj, err := s.Client.GetObject(ctx, s.Bucket, s.objectName(key), minio.GetObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get snapshot of %s: %w", key, err)
+	}
+	defer obj.Close()
+	_, err = obj.Stat()
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get snapshot of %s: %w", key, err)
+	}
+
+	// Extract next to the final directory so a failed restore never leaves a partial workspace behind
+	err = os.MkdirAll(filepath.Dir(dir), 0755)
+	if err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dir), ".restore-"+filepath.Base(dir)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	err = extractTarGz(obj, tmpDir)
+	if err != nil {
+		return fmt.Errorf("failed to extract snapshot of %s: %w", key, err)
+	}
+
+	return os.Rename(tmpDir, dir)
+}
+
+func (s *S3Storage) Save(ctx context.Context, key, dir string) error {
+	// The snapshot is buffered on disk so it is uploaded with a known size in a single request
+	tmp, err := os.CreateTemp(filepath.Dir(dir), ".snapshot-"+filepath.Base(dir)+"-*.tar.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	err = writeTarGz(tmp, dir)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot %s: %w", key, err)
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Client.PutObject(ctx, s.Bucket, s.objectName(key), tmp, size, minio.PutObjectOptions{
+		ContentType: "application/gzip",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save snapshot of %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	err := s.Client.RemoveObject(ctx, s.Bucket, s.objectName(key), minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot of %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (s *S3Storage) List(ctx context.Context) (map[string]time.Time, error) {
+	prefix := ""
+	if s.Prefix != "" {
+		prefix = strings.TrimSuffix(s.Prefix, "/") + "/"
+	}
+
+	snapshots := map[string]time.Time{}
+	for obj := range s.Client.ListObjects(ctx, s.Bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("failed to list snapshots: %w", obj.Err)
+		}
+		key, ok := strings.CutSuffix(strings.TrimPrefix(obj.Key, prefix), ".tar.gz")
+		if !ok {
+			continue
+		}
+		snapshots[key] = obj.LastModified
+	}
+
+	return snapshots, nil
+}
+
+func writeTarGz(w io.Writer, dir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		for _, exclude := range snapshotExcludes {
+			if rel == exclude {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		if d.Type()&fs.ModeSymlink != 0 {
+			link, err = os.Readlink(p)
+			if err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func extractTarGz(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if !withinDir(dir, target) {
+			return fmt.Errorf("invalid path %q in snapshot", hdr.Name)
+		}
+		// A symlink extracted earlier must not redirect this entry outside dir
+		err = checkNoSymlinks(dir, target)
+		if err != nil {
+			return fmt.Errorf("invalid path %q in snapshot: %w", hdr.Name, err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) || !withinDir(dir, filepath.Join(filepath.Dir(target), hdr.Linkname)) {
+				return fmt.Errorf("invalid link %q -> %q in snapshot", hdr.Name, hdr.Linkname)
+			}
+			err = os.MkdirAll(filepath.Dir(target), 0755)
+			if err == nil {
+				err = os.Symlink(hdr.Linkname, target)
+			}
+		case tar.TypeReg:
+			err = writeFile(target, tr, hdr.FileInfo().Mode().Perm())
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// withinDir reports whether path is inside dir, lexically.
+func withinDir(dir, path string) bool {
+	return strings.HasPrefix(filepath.Clean(path), filepath.Clean(dir)+string(os.PathSeparator))
+}
+
+// checkNoSymlinks returns an error if target or any of its parents below dir is a symlink.
+func checkNoSymlinks(dir, target string) error {
+	rel, err := filepath.Rel(dir, target)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Clean(dir)
+	for _, part := range strings.Split(rel, string(os.PathSeparator)) {
+		path = filepath.Join(path, part)
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink", path)
+		}
+	}
+
+	return nil
+}
+
+func writeFile(target string, r io.Reader, perm fs.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,267 @@
+package runner
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is an in-memory object store speaking just enough of the S3 API for S3Storage.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	if r.Method == http.MethodGet && r.URL.Query().Has("list-type") {
+		f.list(w, key, r.URL.Query().Get("prefix"))
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			body = decodeAWSChunked(body)
+		}
+		f.objects[key] = body
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodGet, http.MethodHead:
+		body, ok := f.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`)
+			}
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", "Mon, 19 Oct 2026 00:00:00 GMT")
+		w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// list answers a ListObjectsV2 request of the bucket in path, which must be of the form <bucket>/.
+func (f *fakeS3) list(w http.ResponseWriter, path, prefix string) {
+	bucket := strings.TrimSuffix(path, "/")
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprintf(w, `<ListBucketResult><Name>%s</Name><Prefix>%s</Prefix><IsTruncated>false</IsTruncated>`, bucket, prefix)
+	for key, body := range f.objects {
+		name, ok := strings.CutPrefix(key, bucket+"/")
+		if !ok || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		fmt.Fprintf(w, `<Contents><Key>%s</Key><LastModified>2026-10-19T00:00:00.000Z</LastModified><Size>%d</Size></Contents>`, name, len(body))
+	}
+	io.WriteString(w, `</ListBucketResult>`)
+}
+
+// decodeAWSChunked strips the chunk framing of a streaming signed upload, "<size>;chunk-signature=<sig>\r\n<data>\r\n".
+func decodeAWSChunked(body []byte) []byte {
+	var data []byte
+	for len(body) > 0 {
+		header, rest, _ := bytes.Cut(body, []byte("\r\n"))
+		sizeHex, _, _ := bytes.Cut(header, []byte(";"))
+		size, err := strconv.ParseInt(string(sizeHex), 16, 64)
+		if err != nil || size == 0 {
+			break
+		}
+		data = append(data, rest[:size]...)
+		body = rest[size+2:]
+	}
+	return data
+}
+
+func newFakeS3Storage(t *testing.T) (*S3Storage, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "access")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	s, err := NewS3Storage(srv.URL, "us-east-1", "snapshots", "krec")
+	require.NoError(t, err)
+	return s, fake
+}
+
+func TestS3StorageRoundTrip(t *testing.T) {
+	s, fake := newFakeS3Storage(t)
+	ctx := context.Background()
+
+	dir := filepath.Join(t.TempDir(), "default", "ws")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".terraform", "modules", "m"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".terraform", "providers", "registry.terraform.io"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(`module "m" {}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "errored.tfstate"), []byte(`{}`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".terraform", "modules", "m", "main.tf"), []byte(`# module`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".terraform", initHashFile), []byte("hash"), 0644))
+	require.NoError(t, os.Symlink("main.tf", filepath.Join(dir, "link.tf")))
+
+	require.NoError(t, s.Save(ctx, "default/ws", dir))
+	assert.Contains(t, fake.objects, "snapshots/krec/default/ws.tar.gz")
+
+	restored := filepath.Join(t.TempDir(), "default", "ws")
+	require.NoError(t, s.Restore(ctx, "default/ws", restored))
+
+	content, err := os.ReadFile(filepath.Join(restored, "errored.tfstate"))
+	require.NoError(t, err)
+	assert.Equal(t, `{}`, string(content))
+	info, err := os.Stat(filepath.Join(restored, "errored.tfstate"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	content, err = os.ReadFile(filepath.Join(restored, ".terraform", "modules", "m", "main.tf"))
+	require.NoError(t, err)
+	assert.Equal(t, `# module`, string(content))
+	link, err := os.Readlink(filepath.Join(restored, "link.tf"))
+	require.NoError(t, err)
+	assert.Equal(t, "main.tf", link)
+
+	assert.NoDirExists(t, filepath.Join(restored, ".terraform", "providers"), "providers live in the plugin cache")
+	assert.NoFileExists(t, filepath.Join(restored, ".terraform", initHashFile), "restored workspaces must be initialised again")
+
+	require.NoError(t, s.Delete(ctx, "default/ws"))
+	assert.Empty(t, fake.objects)
+}
+
+func TestCollectSnapshots(t *testing.T) {
+	s, fake := newFakeS3Storage(t)
+	ctx := context.Background()
+	e := New(t.TempDir())
+	e.Storage = s
+
+	for _, key := range []string{"default/live", "default/deleted"} {
+		require.NoError(t, s.Save(ctx, key, t.TempDir()))
+	}
+	fake.objects["snapshots/unrelated.txt"] = []byte("not a snapshot")
+
+	snapshots, err := s.List(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"default/live", "default/deleted"}, slices.Collect(maps.Keys(snapshots)))
+
+	inUse := InUse{Workspaces: map[string]bool{"default/live": true}}
+	lastSaved := snapshots["default/deleted"]
+	require.NoError(t, e.CollectSnapshots(ctx, inUse, lastSaved))
+	assert.Contains(t, fake.objects, "snapshots/krec/default/deleted.tar.gz", "saved too recently to be removed")
+
+	require.NoError(t, e.CollectSnapshots(ctx, inUse, lastSaved.Add(time.Second)))
+	assert.Contains(t, fake.objects, "snapshots/krec/default/live.tar.gz")
+	assert.NotContains(t, fake.objects, "snapshots/krec/default/deleted.tar.gz")
+	assert.Contains(t, fake.objects, "snapshots/unrelated.txt")
+}
+
+func TestS3StorageRestoreIsLazy(t *testing.T) {
+	s, fake := newFakeS3Storage(t)
+	ctx := context.Background()
+
+	// Nothing saved yet
+	dir := filepath.Join(t.TempDir(), "default", "ws")
+	require.NoError(t, s.Restore(ctx, "default/ws", dir))
+	assert.NoDirExists(t, dir)
+
+	// An existing directory is never overwritten by an older snapshot
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte("local"), 0644))
+	fake.objects["snapshots/krec/default/ws.tar.gz"] = []byte("not a tarball")
+	require.NoError(t, s.Restore(ctx, "default/ws", dir))
+	content, err := os.ReadFile(filepath.Join(dir, "main.tf"))
+	require.NoError(t, err)
+	assert.Equal(t, "local", string(content))
+}
+
+func TestGetTerraformForWorkspaceRestoresFromStorage(t *testing.T) {
+	s, _ := newFakeS3Storage(t)
+	ctx := context.Background()
+
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "errored.tfstate"), []byte(`{}`), 0600))
+	require.NoError(t, s.Save(ctx, "default/test-workspace", src))
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "1.11.2"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1.11.2", "terraform"), []byte(fakeTerraform), 0755))
+	e := New(t.TempDir())
+	e.SetTerraformDir(dir)
+	e.Storage = s
+
+	tf, _, err := e.GetTerraformForWorkspace(ctx, newTestWorkspace(), version.Must(version.NewVersion("1.11.2")), "")
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(tf.WorkingDir(), "errored.tfstate"))
+
+	require.NoError(t, e.DeleteWorkspace(ctx, newTestWorkspace()))
+	assert.NoDirExists(t, e.WorkspaceDir(newTestWorkspace()))
+	require.NoError(t, e.EndRun(newTestWorkspace(), tf.WorkingDir(), false))
+	assert.NoDirExists(t, tf.WorkingDir())
+}
+
+type tarEntry struct {
+	name, link, content string
+}
+
+func tarGz(t *testing.T, entries ...tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.content))}
+		if e.link != "" {
+			hdr = &tar.Header{Name: e.name, Mode: 0777, Typeflag: tar.TypeSymlink, Linkname: e.link}
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(e.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestExtractTarGzRejectsEscapes(t *testing.T) {
+	for name, entries := range map[string][]tarEntry{
+		"absolute link":            {{name: "escape", link: "/etc"}},
+		"relative link outside":    {{name: "sub/escape", link: "../../outside"}},
+		"path outside":             {{name: "../outside.tf", content: "x"}},
+		"write through link":       {{name: "sub/main.tf", content: "x"}, {name: "link", link: "sub"}, {name: "link/main.tf", content: "y"}},
+		"overwrite link with file": {{name: "main.tf", content: "x"}, {name: "link.tf", link: "main.tf"}, {name: "link.tf", content: "y"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "ws")
+			require.NoError(t, os.MkdirAll(dir, 0755))
+
+			err := extractTarGz(bytes.NewReader(tarGz(t, entries...)), dir)
+			assert.ErrorContains(t, err, "in snapshot")
+			assert.NoFileExists(t, filepath.Join(root, "outside.tf"))
+			content, err := os.ReadFile(filepath.Join(dir, "sub", "main.tf"))
+			if err == nil {
+				assert.Equal(t, "x", string(content))
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package runner
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// Terraform runs the commands of the engine in the directory of a run. NewTFExec runs them with the engine
+// binary through terraform-exec, the fake package has an implementation that runs nothing for tests.
+type Terraform interface {
+	// WorkingDir is the directory the commands are run in.
+	WorkingDir() string
+	// SetEnv sets the environment of the commands, on top of the environment of the operator.
+	SetEnv(env map[string]string) error
+
+	Init(ctx context.Context, upgrade bool) error
+	Validate(ctx context.Context) (*tfjson.ValidateOutput, error)
+	// Plan writes the plan to planFile and reports whether it has changes.
+	Plan(ctx context.Context, planFile string) (bool, error)
+	ShowPlanFile(ctx context.Context, planFile string) (*tfjson.Plan, error)
+	ShowPlanFileRaw(ctx context.Context, planFile string) (string, error)
+	Apply(ctx context.Context) error
+	Destroy(ctx context.Context) error
+	Output(ctx context.Context) (map[string]tfexec.OutputMeta, error)
+	StatePush(ctx context.Context, path string) error
+	ForceUnlock(ctx context.Context, lockID string) error
+}
+
+// InterruptWaitDelay is how long terraform is given to stop after it is interrupted, releasing its locks and
+// persisting its state, before it is killed.
+const InterruptWaitDelay = time.Minute
+
+// NewTerraformFunc creates the Terraform of a run in workingDir using the engine binary at execPath.
+type NewTerraformFunc func(workingDir, execPath string) (Terraform, error)
+
+// tfexecTerraform is the Terraform that runs the engine binary through terraform-exec.
+type tfexecTerraform struct {
+	tf *tfexec.Terraform
+}
+
+// NewTFExec creates a Terraform that runs the engine binary at execPath in workingDir.
+func NewTFExec(workingDir, execPath string) (Terraform, error) {
+	tf, err := tfexec.NewTerraform(workingDir, execPath)
+	if err != nil {
+		return nil, err
+	}
+	// Only fails on windows, where terraform is killed right away
+	_ = tf.SetWaitDelay(InterruptWaitDelay)
+
+	return &tfexecTerraform{tf: tf}, nil
+}
+
+func (t *tfexecTerraform) WorkingDir() string {
+	return t.tf.WorkingDir()
+}
+
+func (t *tfexecTerraform) SetEnv(env map[string]string) error {
+	return t.tf.SetEnv(env)
+}
+
+func (t *tfexecTerraform) Init(ctx context.Context, upgrade bool) error {
+	return t.tf.Init(ctx, tfexec.Upgrade(upgrade))
+}
+
+func (t *tfexecTerraform) Validate(ctx context.Context) (*tfjson.ValidateOutput, error) {
+	return t.tf.Validate(ctx)
+}
+
+func (t *tfexecTerraform) Plan(ctx context.Context, planFile string) (bool, error) {
+	return t.tf.Plan(ctx, tfexec.Out(planFile))
+}
+
+func (t *tfexecTerraform) ShowPlanFile(ctx context.Context, planFile string) (*tfjson.Plan, error) {
+	return t.tf.ShowPlanFile(ctx, planFile)
+}
+
+func (t *tfexecTerraform) ShowPlanFileRaw(ctx context.Context, planFile string) (string, error) {
+	return t.tf.ShowPlanFileRaw(ctx, planFile)
+}
+
+func (t *tfexecTerraform) Apply(ctx context.Context) error {
+	return t.tf.Apply(ctx)
+}
+
+func (t *tfexecTerraform) Destroy(ctx context.Context) error {
+	return t.tf.Destroy(ctx)
+}
+
+func (t *tfexecTerraform) Output(ctx context.Context) (map[string]tfexec.OutputMeta, error) {
+	return t.tf.Output(ctx)
+}
+
+func (t *tfexecTerraform) StatePush(ctx context.Context, path string) error {
+	return t.tf.StatePush(ctx, path)
+}
+
+func (t *tfexecTerraform) ForceUnlock(ctx context.Context, lockID string) error {
+	return t.tf.ForceUnlock(ctx, lockID)
+}
@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"golang.org/x/sync/singleflight"
+	tfreconcilev1alpha1 "lukaspj.io/kube-tf-reconciler/api/v1alpha1"
+)
+
+// ErrInvalidVersion is returned when a workspace version is neither a version nor a version constraint, or a
+// constraint no available version matches.
+var ErrInvalidVersion = errors.New("invalid version")
+
+// versionListTTL is how long the list of available versions of an engine is reused before it is fetched again.
+const versionListTTL = time.Hour
+
+type cachedVersions struct {
+	versions  []*version.Version
+	fetchedAt time.Time
+}
+
+// versionCache caches the available versions of each engine. The versions of an engine are fetched once for
+// all workspaces waiting for them, without holding up the engines that are cached already.
+type versionCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedVersions
+	fetches singleflight.Group
+	now     func() time.Time
+}
+
+func newVersionCache() *versionCache {
+	return &versionCache{
+		entries: map[string]cachedVersions{},
+		now:     time.Now,
+	}
+}
+
+func (c *versionCache) list(ctx context.Context, engine string, installer Installer) ([]*version.Version, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[engine]
+	c.mu.Unlock()
+	if ok && c.now().Sub(entry.fetchedAt) < versionListTTL {
+		return entry.versions, nil
+	}
+
+	fetch := c.fetches.DoChan(engine, func() (interface{}, error) {
+		versions, err := installer.Versions(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s versions: %w", engine, err)
+		}
+		sort.Sort(version.Collection(versions))
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.entries[engine] = cachedVersions{versions: versions, fetchedAt: c.now()}
+		return versions, nil
+	})
+
+	select {
+	case result := <-fetch:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.([]*version.Version), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// ResolveVersion resolves the version of the workspace engine. An exact version is used as is, a version
+// constraint such as "~> 1.9" resolves to the newest available version matching it.
+func (e *Exec) ResolveVersion(ctx context.Context, ws tfreconcilev1alpha1.Workspace) (*version.Version, error) {
+	engine, installer, err := e.installerFor(ws)
+	if err != nil {
+		return nil, err
+	}
+
+	if v, err := version.NewVersion(ws.Spec.TerraformVersion); err == nil {
+		return v, nil
+	}
+
+	constraints, err := version.NewConstraint(ws.Spec.TerraformVersion)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidVersion, ws.Spec.TerraformVersion, err)
+	}
+
+	versions, err := e.versions.list(ctx, engine, installer)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		if constraints.Check(versions[i]) {
+			return versions[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: no %s version matches %q", ErrInvalidVersion, engine, ws.Spec.TerraformVersion)
+}
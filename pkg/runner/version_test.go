@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tofuIndexServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"versions":[
+			{"id":"1.10.0-beta1"},
+			{"id":"1.9.1"},
+			{"id":"1.8.8"},
+			{"id":"1.9.0"},
+			{"id":"1.8.2"},
+			{"id":"not-a-version"}
+		]}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestResolveVersion(t *testing.T) {
+	srv, requests := tofuIndexServer(t)
+	e := New(t.TempDir())
+	e.SetTofuMirror(srv.URL, srv.URL, "")
+
+	ws := newTestWorkspace()
+	ws.Spec.Engine = EngineOpenTofu
+
+	for constraint, expected := range map[string]string{
+		"1.7.3":           "1.7.3",
+		"~> 1.8.0":        "1.8.8",
+		"~> 1.8":          "1.9.1",
+		">= 1.8, < 1.9.1": "1.9.0",
+		"1.10.0-beta1":    "1.10.0-beta1",
+	} {
+		ws.Spec.TerraformVersion = constraint
+		v, err := e.ResolveVersion(context.Background(), ws)
+		require.NoError(t, err, constraint)
+		assert.Equal(t, expected, v.String(), constraint)
+	}
+	assert.Equal(t, int32(1), requests.Load(), "the version list should be cached")
+
+	ws.Spec.TerraformVersion = "~> 2.0"
+	_, err := e.ResolveVersion(context.Background(), ws)
+	assert.ErrorContains(t, err, "no opentofu version matches")
+	assert.ErrorIs(t, err, ErrInvalidVersion, "retrying won't help until the spec changes")
+
+	ws.Spec.TerraformVersion = "latest please"
+	_, err = e.ResolveVersion(context.Background(), ws)
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+}
+
+func TestResolveVersionCacheExpires(t *testing.T) {
+	srv, requests := tofuIndexServer(t)
+	e := New(t.TempDir())
+	e.SetTofuMirror(srv.URL, srv.URL, "")
+	now := time.Now()
+	e.versions.now = func() time.Time { return now }
+
+	ws := newTestWorkspace()
+	ws.Spec.Engine = EngineOpenTofu
+	ws.Spec.TerraformVersion = "~> 1.9"
+
+	_, err := e.ResolveVersion(context.Background(), ws)
+	require.NoError(t, err)
+	now = now.Add(versionListTTL + time.Second)
+	_, err = e.ResolveVersion(context.Background(), ws)
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+// blockingInstaller lists versions once released, counting the lists in progress.
+type blockingInstaller struct {
+	failingInstaller
+	release chan struct{}
+	lists   atomic.Int32
+}
+
+func (i *blockingInstaller) Versions(ctx context.Context) ([]*version.Version, error) {
+	i.lists.Add(1)
+	<-i.release
+	return []*version.Version{version.Must(version.NewVersion("1.9.0"))}, nil
+}
+
+func TestResolveVersionDoesNotWaitForOtherEngines(t *testing.T) {
+	srv, _ := tofuIndexServer(t)
+	e := New(t.TempDir())
+	e.SetTofuMirror(srv.URL, srv.URL, "")
+	slow := &blockingInstaller{release: make(chan struct{})}
+	e.Installers[EngineTerraform] = slow
+
+	ws := newTestWorkspace()
+	ws.Spec.TerraformVersion = "~> 1.9"
+	results := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := e.ResolveVersion(context.Background(), ws)
+			results <- err
+		}()
+	}
+
+	tofu := newTestWorkspace()
+	tofu.Spec.Engine = EngineOpenTofu
+	tofu.Spec.TerraformVersion = "~> 1.9"
+	v, err := e.ResolveVersion(context.Background(), tofu)
+	require.NoError(t, err, "a slow index of one engine must not hold up the others")
+	assert.Equal(t, "1.9.1", v.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = e.ResolveVersion(ctx, ws)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "waiting for a fetch gives up with the context")
+
+	close(slow.release)
+	for range 2 {
+		assert.NoError(t, <-results)
+	}
+	assert.Equal(t, int32(1), slow.lists.Load(), "workspaces waiting for the same engine share the fetch")
+}
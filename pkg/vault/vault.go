@@ -0,0 +1,169 @@
+// Package vault is a minimal client of the Vault HTTP API, covering what the operator needs to hand dynamic
+// secrets to terraform runs: Kubernetes auth, reading secrets and renewing and revoking leases and tokens.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client talks to the Vault server at Address.
+type Client struct {
+	Address string
+	// Namespace is the Vault Enterprise namespace requests are made in. Empty means the root namespace.
+	Namespace string
+	// HTTPClient makes the requests, a client with a timeout if nil.
+	HTTPClient *http.Client
+}
+
+// Secret is the response to a read of a secret.
+type Secret struct {
+	LeaseID       string         `json:"lease_id"`
+	LeaseDuration int            `json:"lease_duration"`
+	Renewable     bool           `json:"renewable"`
+	Data          map[string]any `json:"data"`
+}
+
+// Auth is the token part of the response to a login or a token renewal.
+type Auth struct {
+	ClientToken string `json:"client_token"`
+	// LeaseDuration is the number of seconds the token is valid for, 0 if it does not expire.
+	LeaseDuration int  `json:"lease_duration"`
+	Renewable     bool `json:"renewable"`
+}
+
+// LoginKubernetes logs in with a service account token through the Kubernetes auth method mounted at
+// authPath, as the given role.
+func (c *Client) LoginKubernetes(ctx context.Context, authPath, role, jwt string) (*Auth, error) {
+	var resp struct {
+		Auth *Auth `json:"auth"`
+	}
+	path := fmt.Sprintf("auth/%s/login", strings.Trim(authPath, "/"))
+	err := c.do(ctx, http.MethodPost, path, "", map[string]string{"jwt": jwt, "role": role}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log in to vault: %w", err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("failed to log in to vault: no token in response")
+	}
+
+	return resp.Auth, nil
+}
+
+// Read reads the secret at path.
+func (c *Client) Read(ctx context.Context, token, path string) (*Secret, error) {
+	var secret Secret
+	err := c.do(ctx, http.MethodGet, strings.Trim(path, "/"), token, nil, &secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return &secret, nil
+}
+
+// RenewLease extends the lease of a secret by its default duration. The returned secret has no data.
+func (c *Client) RenewLease(ctx context.Context, token, leaseID string) (*Secret, error) {
+	var secret Secret
+	err := c.do(ctx, http.MethodPut, "sys/leases/renew", token, map[string]string{"lease_id": leaseID}, &secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to renew lease %s: %w", leaseID, err)
+	}
+
+	return &secret, nil
+}
+
+// RenewSelf extends the lifetime of the token itself by its default duration.
+func (c *Client) RenewSelf(ctx context.Context, token string) (*Auth, error) {
+	var resp struct {
+		Auth *Auth `json:"auth"`
+	}
+	err := c.do(ctx, http.MethodPost, "auth/token/renew-self", token, map[string]string{}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to renew token: %w", err)
+	}
+	if resp.Auth == nil {
+		return nil, fmt.Errorf("failed to renew token: no token in response")
+	}
+
+	return resp.Auth, nil
+}
+
+// RevokeLease revokes the lease of a secret, which makes the secret engine delete the credentials.
+func (c *Client) RevokeLease(ctx context.Context, token, leaseID string) error {
+	err := c.do(ctx, http.MethodPut, "sys/leases/revoke", token, map[string]string{"lease_id": leaseID}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to revoke lease %s: %w", leaseID, err)
+	}
+
+	return nil
+}
+
+// RevokeSelf revokes the token itself.
+func (c *Client) RevokeSelf(ctx context.Context, token string) error {
+	err := c.do(ctx, http.MethodPost, "auth/token/revoke-self", token, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method, path, token string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.Address, "/")+"/v1/"+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if c.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var errResp struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(content, &errResp) == nil && len(errResp.Errors) > 0 {
+			return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.Join(errResp.Errors, "; "))
+		}
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	if out == nil || len(content) == 0 {
+		return nil
+	}
+	return json.Unmarshal(content, out)
+}
@@ -0,0 +1,83 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	var revoked []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/auth/k8s/login", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "jwt", body["jwt"])
+		assert.Equal(t, "terraform", body["role"])
+		assert.Equal(t, "team", r.Header.Get("X-Vault-Namespace"))
+		w.Write([]byte(`{"auth": {"client_token": "vault-token", "lease_duration": 1800, "renewable": true}}`))
+	})
+	mux.HandleFunc("GET /v1/aws/creds/terraform", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "vault-token", r.Header.Get("X-Vault-Token"))
+		w.Write([]byte(`{"lease_id": "aws/creds/terraform/1", "lease_duration": 3600, "data": {"access_key": "AKIA"}}`))
+	})
+	mux.HandleFunc("PUT /v1/sys/leases/renew", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "aws/creds/terraform/1", body["lease_id"])
+		w.Write([]byte(`{"lease_id": "aws/creds/terraform/1", "lease_duration": 3600, "renewable": true}`))
+	})
+	mux.HandleFunc("POST /v1/auth/token/renew-self", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "vault-token", r.Header.Get("X-Vault-Token"))
+		w.Write([]byte(`{"auth": {"client_token": "vault-token", "lease_duration": 1800, "renewable": true}}`))
+	})
+	mux.HandleFunc("PUT /v1/sys/leases/revoke", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		revoked = append(revoked, body["lease_id"])
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /v1/auth/token/revoke-self", func(w http.ResponseWriter, r *http.Request) {
+		revoked = append(revoked, r.Header.Get("X-Vault-Token"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /v1/secret/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors": ["permission denied"]}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := &Client{Address: srv.URL, Namespace: "team"}
+	auth, err := c.LoginKubernetes(ctx, "k8s", "terraform", "jwt")
+	require.NoError(t, err)
+	assert.Equal(t, "vault-token", auth.ClientToken)
+	assert.Equal(t, 1800, auth.LeaseDuration)
+	assert.True(t, auth.Renewable)
+	token := auth.ClientToken
+
+	secret, err := c.Read(ctx, token, "aws/creds/terraform")
+	require.NoError(t, err)
+	assert.Equal(t, "aws/creds/terraform/1", secret.LeaseID)
+	assert.Equal(t, "AKIA", secret.Data["access_key"])
+
+	renewed, err := c.RenewLease(ctx, token, secret.LeaseID)
+	require.NoError(t, err)
+	assert.Equal(t, 3600, renewed.LeaseDuration)
+	renewedAuth, err := c.RenewSelf(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, 1800, renewedAuth.LeaseDuration)
+
+	_, err = c.Read(ctx, token, "secret/missing")
+	assert.ErrorContains(t, err, "permission denied")
+
+	require.NoError(t, c.RevokeLease(ctx, token, secret.LeaseID))
+	require.NoError(t, c.RevokeSelf(ctx, token))
+	assert.Equal(t, []string{"aws/creds/terraform/1", "vault-token"}, revoked)
+}